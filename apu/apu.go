@@ -20,6 +20,8 @@ var CounterTable = [...]uint{
 type Generator interface {
 	GetStreamer() beep.Streamer
 	Write(byte, byte)
+	// SetEnabled is the channel's bit of 0x4015, a disabled channel is silenced.
+	SetEnabled(bool)
 }
 
 type Apu struct {
@@ -32,7 +34,8 @@ type Apu struct {
 	triangle      Generator
 	square0       Generator
 	square1       Generator
-	enableIrq     bool
+	isIrqInhibit  bool
+	isFrameIrq    bool
 	sequencerMode bool
 }
 
@@ -45,10 +48,35 @@ func NewApu(interrupts *interrupts.Interrupts) *Apu {
 		square0:    NewSquare(),
 		square1:    NewSquare(),
 	}
+	return a
+}
+
+// Play opens the audio device and starts playing the generators.
+func (A *Apu) Play() {
 	sr := beep.SampleRate(22050)
 	speaker.Init(sr, sr.N(time.Second/10))
-	speaker.Play(a.noise.GetStreamer(), a.triangle.GetStreamer())
-	return a
+	speaker.Play(A.noise.GetStreamer(), A.triangle.GetStreamer())
+}
+
+// PowerOn behaves as if 0x00 was written to 0x4017.
+func (A *Apu) PowerOn() {
+	A.sequencerMode = false
+	A.isIrqInhibit = false
+	A.registers[0x17] = 0
+	A.reset()
+}
+
+// Reset keeps the frame counter mode, the sequencer restarts as if 0x4017 was written again.
+func (A *Apu) Reset() {
+	A.reset()
+}
+
+// Both power-on and reset silence all channels (0x4015 = 0).
+func (A *Apu) reset() {
+	A.Write(0x15, 0)
+	A.clearFrameIrq()
+	A.step = 0
+	A.cycle = 0
 }
 
 func (A *Apu) Write(addr uint, data byte) {
@@ -61,14 +89,43 @@ func (A *Apu) Write(addr uint, data byte) {
 		A.triangle.Write(byte((addr-0x08)&0xff), data)
 	} else if addr <= 0x0f {
 		A.noise.Write(byte((addr-0x0c)&0xff), data)
+	} else if addr == 0x15 {
+		A.registers[addr] = data
+		A.square0.SetEnabled(common.I2b(uint(data & 0x01)))
+		A.square1.SetEnabled(common.I2b(uint(data & 0x02)))
+		A.triangle.SetEnabled(common.I2b(uint(data & 0x04)))
+		A.noise.SetEnabled(common.I2b(uint(data & 0x08)))
 	} else if addr == 0x17 {
 		A.sequencerMode = common.I2b(uint(data & 0x80))
 		A.registers[addr] = data
-		A.enableIrq = common.I2b(uint(data & 0x40))
+		A.isIrqInhibit = common.I2b(uint(data & 0x40))
+		if A.isIrqInhibit {
+			A.clearFrameIrq()
+		}
 	}
 	//A.ram.Write(addr, data)
 }
 
+// Read returns the status register (0x4015), reading it acknowledges the frame IRQ.
+func (A *Apu) Read(addr uint) byte {
+	var data byte = 0
+	if addr == 0x15 {
+		if A.isFrameIrq {
+			data |= 0x40
+		}
+		A.clearFrameIrq()
+	}
+	return data
+}
+
+func (A *Apu) clearFrameIrq() {
+	if !A.isFrameIrq {
+		return
+	}
+	A.isFrameIrq = false
+	A.interrupts.ReleaseIrq(interrupts.IrqApuFrame)
+}
+
 func (A *Apu) updateEnvelope() {
 
 }
@@ -84,8 +141,9 @@ func (A *Apu) updateBySequenceMode0() {
 	}
 	A.step++
 	if A.step == 4 {
-		if A.enableIrq {
-			A.interrupts.AssertIrq()
+		if !A.isIrqInhibit {
+			A.isFrameIrq = true
+			A.interrupts.AssertIrq(interrupts.IrqApuFrame)
 		}
 		A.step = 0
 	}
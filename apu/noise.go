@@ -25,3 +25,7 @@ func (n *Noise) Write(addr byte, data byte) {
 	//fmt.Printf("NO: 0x%02x - 0x%02x\n", addr, data)
 	//n.ram.Write(addr, data)
 }
+
+// The noise channel doesn't play anything yet.
+func (n *Noise) SetEnabled(isEnabled bool) {
+}
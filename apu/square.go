@@ -29,3 +29,9 @@ func (s *Square) GetStreamer() beep.Streamer {
 func (s *Square) Write(addr byte, data byte) {
 	//fmt.Printf("SQ: 0x%02x - 0x%02x\n", addr, data)
 }
+
+func (s *Square) SetEnabled(isEnabled bool) {
+	if !isEnabled {
+		s.stopped = true
+	}
+}
@@ -14,6 +14,8 @@ type Triangle struct {
 	direction             int16
 	isLengthCounterEnable bool
 	stopped               bool
+	// 0x4015 bit 2
+	isEnabled bool
 }
 
 func NewTriangle() *Triangle {
@@ -82,11 +84,20 @@ func (t *Triangle) Write(addr byte, data byte) {
 		// Programmable timer, length counter
 		t.dividerForFrequency &= 0xFF
 		t.dividerForFrequency |= uint((data & 0x7) << 8)
-		if t.isLengthCounterEnable {
+		if t.isLengthCounterEnable && t.isEnabled {
 			t.lengthCounter = CounterTable[data>>3]
 		}
 		t.frequency = common.CpuClock / ((t.dividerForFrequency + 1) * 32)
-		t.stopped = false
+		t.stopped = !t.isEnabled
+	}
+}
+
+// Disabling clears the length counter, the channel stays silent until it is enabled and restarted.
+func (t *Triangle) SetEnabled(isEnabled bool) {
+	t.isEnabled = isEnabled
+	if !isEnabled {
+		t.lengthCounter = 0
+		t.stopped = true
 	}
 }
 
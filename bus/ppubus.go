@@ -1,19 +1,74 @@
 package bus
 
+import "github.com/popsul/gones/mapper"
+
+const CIRAM_SIZE = 0x0800
+
 type PpuBus struct {
-	ram *Ram
+	mapper  mapper.Mapper
+	clocked mapper.PpuClocked
+	ciram   *Ram
 }
 
-func NewPpuBus(ram *Ram) *PpuBus {
+func NewPpuBus(m mapper.Mapper) *PpuBus {
 	ppuBus := new(PpuBus)
-	ppuBus.ram = ram
+	ppuBus.mapper = m
+	ppuBus.ciram = NewRam(CIRAM_SIZE)
+	ppuBus.clocked, _ = m.(mapper.PpuClocked)
 	return ppuBus
 }
 
+// PowerOn clears the console's nametable RAM, a reset keeps it.
+func (p *PpuBus) PowerOn() {
+	p.ciram.Reset()
+}
+
+// Tick is called by the PPU on every dot.
+func (p *PpuBus) Tick() {
+	if p.clocked != nil {
+		p.clocked.ClockPpu()
+	}
+}
+
 func (p *PpuBus) ReadByPpu(addr uint) byte {
-	return p.ram.Read(addr)
+	return p.mapper.ReadByPpu(addr)
 }
 
 func (p *PpuBus) WriteByPpu(addr uint, value byte) {
-	p.ram.Write(addr, value)
+	p.mapper.WriteByPpu(addr, value)
+}
+
+func (p *PpuBus) Mirroring() mapper.Mirroring {
+	return p.mapper.Mirroring()
+}
+
+// ReadNametable reads the nametable area, addr is relative to 0x2000 (0x0000-0x0FFF).
+func (p *PpuBus) ReadNametable(addr uint) byte {
+	page, offset := p.nametablePage(addr)
+	if page < 2 {
+		return p.ciram.Read(page*mapper.NAMETABLE_SIZE + offset)
+	}
+	vram := p.mapper.CartridgeVram()
+	if len(vram) == 0 {
+		return 0
+	}
+	return vram[((page-2)*mapper.NAMETABLE_SIZE+offset)%uint(len(vram))]
+}
+
+func (p *PpuBus) WriteNametable(addr uint, data byte) {
+	page, offset := p.nametablePage(addr)
+	if page < 2 {
+		p.ciram.Write(page*mapper.NAMETABLE_SIZE+offset, data)
+		return
+	}
+	vram := p.mapper.CartridgeVram()
+	if len(vram) == 0 {
+		return
+	}
+	vram[((page-2)*mapper.NAMETABLE_SIZE+offset)%uint(len(vram))] = data
+}
+
+func (p *PpuBus) nametablePage(addr uint) (uint, uint) {
+	table := (addr / mapper.NAMETABLE_SIZE) % 4
+	return p.mapper.NametablePage(table) % 4, addr % mapper.NAMETABLE_SIZE
 }
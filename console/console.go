@@ -0,0 +1,122 @@
+package console
+
+import (
+	"github.com/popsul/gones/apu"
+	"github.com/popsul/gones/bus"
+	"github.com/popsul/gones/cpu"
+	"github.com/popsul/gones/interrupts"
+	"github.com/popsul/gones/mapper"
+	"github.com/popsul/gones/ppu"
+	"github.com/popsul/gones/reader"
+)
+
+// Console wires the chips of the NES together. The frontend (video, audio output, saves)
+// stays outside, so it also runs headless.
+type Console struct {
+	cpu        *cpu.Cpu
+	ppu        *ppu.Ppu
+	apu        *apu.Apu
+	dma        *Dma
+	interrupts *interrupts.Interrupts
+
+	cpuBus  *CpuBus
+	ram     *bus.Ram
+	ppuBus  *bus.PpuBus
+	mapper  mapper.Mapper
+	keypad1 *bus.Keypad
+	keypad2 *bus.Keypad
+}
+
+// NewConsole returns a powered on console with the cartridge inserted.
+func NewConsole(rom *reader.NesRom) (*Console, error) {
+	console := new(Console)
+
+	console.keypad1 = bus.NewKeypad()
+	console.keypad2 = bus.NewKeypad()
+	console.ram = bus.NewRam(2048)
+	console.interrupts = interrupts.NewInterrupts()
+
+	m, err := mapper.NewMapper(rom, console.interrupts)
+	if err != nil {
+		return nil, err
+	}
+	console.mapper = m
+
+	console.ppuBus = bus.NewPpuBus(console.mapper)
+
+	console.ppu = ppu.NewPpu(console.ppuBus, console.interrupts)
+	console.dma = NewDma(console.ppu)
+
+	console.apu = apu.NewApu(console.interrupts)
+
+	console.cpuBus = NewCpuBus(console.ram, console.mapper, console.ppu, console.apu, console.keypad1, console.keypad2, console.dma)
+	console.cpu = cpu.NewCpu(console.cpuBus, console.interrupts)
+	console.PowerOn()
+
+	return console, nil
+}
+
+// PowerOn is the power switch: RAM and every chip start over, battery backed PRG-RAM is kept.
+func (C *Console) PowerOn() {
+	C.ram.Reset()
+	C.mapper.PowerOn()
+	C.ppuBus.PowerOn()
+	C.ppu.PowerOn()
+	C.apu.PowerOn()
+	C.dma.Finish()
+	// NOTE: The CPU goes last, its reset sequence reads the vector through the mapper.
+	C.cpu.PowerOn()
+}
+
+// Reset is the reset button: RAM is kept and the chips run their own reset behaviour.
+func (C *Console) Reset() {
+	C.mapper.Reset()
+	C.ppu.Reset()
+	C.apu.Reset()
+	C.dma.Finish()
+	C.cpu.Reset()
+}
+
+// Run executes one CPU instruction and returns its cycles, the rest of the console is ticked along.
+func (C *Console) Run() uint {
+	return C.cpu.Run()
+}
+
+// RenderingData returns the frame finished since the last call, or nil.
+func (C *Console) RenderingData() *ppu.RenderingData {
+	return C.cpuBus.RenderingData()
+}
+
+// Jammed returns the CPU state when a JAM opcode halted it, nil otherwise.
+func (C *Console) Jammed() error {
+	return C.cpu.Jammed()
+}
+
+// Peek reads CPU memory without side effects, see. CpuBus.Peek
+func (C *Console) Peek(addr uint) byte {
+	return C.cpuBus.Peek(addr)
+}
+
+func (C *Console) Cpu() *cpu.Cpu {
+	return C.cpu
+}
+
+func (C *Console) Ppu() *ppu.Ppu {
+	return C.ppu
+}
+
+func (C *Console) Apu() *apu.Apu {
+	return C.apu
+}
+
+func (C *Console) Mapper() mapper.Mapper {
+	return C.mapper
+}
+
+func (C *Console) Keypad1() *bus.Keypad {
+	return C.keypad1
+}
+
+func (C *Console) Keypad2() *bus.Keypad {
+	return C.keypad2
+}
@@ -0,0 +1,53 @@
+package console_test
+
+import (
+	"testing"
+
+	"github.com/popsul/gones/console"
+	"github.com/popsul/gones/reader"
+)
+
+/*
+	Enables IRQs after writing the frame counter, the handler counts the IRQs without acknowledging them.
+
+	8000  LDA #$xx       8010  INC $01
+	8002  STA $4017      8012  RTI
+	8005  CLI
+	8006  JMP $8006
+*/
+
+func newFrameIrqConsole(t *testing.T, frameCounter byte) *console.Console {
+	rom := new(reader.NesRom)
+	rom.Program = make([]byte, 0x8000)
+	copy(rom.Program, []byte{0xA9, frameCounter, 0x8D, 0x17, 0x40, 0x58, 0x4C, 0x06, 0x80})
+	copy(rom.Program[0x10:], []byte{0xE6, 0x01, 0x40})
+	// Reset vector: 0x8000, IRQ vector: 0x8010
+	rom.Program[0x7FFD] = 0x80
+	rom.Program[0x7FFE] = 0x10
+	rom.Program[0x7FFF] = 0x80
+	c, err := console.NewConsole(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestFrameIrqInhibit(t *testing.T) {
+	tests := []struct {
+		frameCounter byte
+		isIrq        bool
+	}{
+		{0x00, true},
+		{0x40, false},
+	}
+	for _, test := range tests {
+		c := newFrameIrqConsole(t, test.frameCounter)
+		// A few frames, the frame IRQ fires every 29830 CPU cycles.
+		for cycles := uint(0); cycles < 100000; {
+			cycles += c.Run()
+		}
+		if isIrq := c.Peek(0x01) > 0; isIrq != test.isIrq {
+			t.Errorf("$4017 = 0x%02X: IRQ taken %t, want %t", test.frameCounter, isIrq, test.isIrq)
+		}
+	}
+}
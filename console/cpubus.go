@@ -0,0 +1,128 @@
+package console
+
+import (
+	"github.com/popsul/gones/apu"
+	"github.com/popsul/gones/bus"
+	"github.com/popsul/gones/mapper"
+	"github.com/popsul/gones/ppu"
+)
+
+// CpuBus is the NES memory map seen by the CPU, it implements cpu.Bus, cpu.Ticker and cpu.DmaBus.
+type CpuBus struct {
+	ram     *bus.Ram
+	mapper  mapper.Mapper
+	clocked mapper.CpuClocked
+	ppu     *ppu.Ppu
+	dma     *Dma
+	keypad1 *bus.Keypad
+	keypad2 *bus.Keypad
+	apu     *apu.Apu
+	// Frame finished by the PPU during the last ticks, nil until then.
+	renderingData *ppu.RenderingData
+}
+
+func NewCpuBus(ram *bus.Ram, m mapper.Mapper, ppu *ppu.Ppu, apu *apu.Apu, keypad1 *bus.Keypad, keypad2 *bus.Keypad, dma *Dma) *CpuBus {
+	cb := new(CpuBus)
+	cb.ram = ram
+	cb.mapper = m
+	cb.clocked, _ = m.(mapper.CpuClocked)
+	cb.ppu = ppu
+	cb.dma = dma
+	cb.keypad1 = keypad1
+	cb.keypad2 = keypad2
+	cb.apu = apu
+	return cb
+}
+
+// Tick runs the rest of the console for one CPU cycle, the PPU runs 3 dots per CPU cycle.
+func (CB *CpuBus) Tick() {
+	if data := CB.ppu.Run(3); data != nil {
+		CB.renderingData = data
+	}
+	CB.apu.Run(1)
+	if CB.clocked != nil {
+		CB.clocked.ClockCpu()
+	}
+}
+
+// RenderingData returns the frame finished since the last call, or nil.
+func (CB *CpuBus) RenderingData() *ppu.RenderingData {
+	data := CB.renderingData
+	CB.renderingData = nil
+	return data
+}
+
+func (CB *CpuBus) IsDmaProcessing() bool {
+	return CB.dma.IsDmaProcessing()
+}
+
+func (CB *CpuBus) DmaAddr() uint {
+	return CB.dma.Addr()
+}
+
+func (CB *CpuBus) TransferDma(index uint, data byte) {
+	CB.dma.Transfer(index, data)
+}
+
+func (CB *CpuBus) FinishDma() {
+	CB.dma.Finish()
+}
+
+func (CB *CpuBus) Read(addr uint) byte {
+	var data byte = 0
+	if addr < 0x2000 {
+		// mirror
+		data = CB.ram.Read(addr % 0x0800)
+	} else if addr < 0x4000 {
+		// mirror
+		data = CB.ppu.Read((addr - 0x2000) % 8)
+	} else if addr == 0x4015 {
+		data = CB.apu.Read(addr - 0x4000)
+	} else if addr == 0x4016 {
+		if CB.keypad1.Read() {
+			data = 1
+		}
+	} else if addr == 0x4017 {
+		if CB.keypad2.Read() {
+			data = 1
+		}
+	} else if addr >= 0x4020 {
+		// Cartridge space: PRG-RAM, PRG-ROM and mapper registers
+		data = CB.mapper.ReadByCpu(addr)
+	}
+
+	return data
+}
+
+// Peek reads RAM and cartridge space without side effects, I/O registers read as 0.
+// It is meant for disassemblers and debuggers, the CPU uses Read.
+func (CB *CpuBus) Peek(addr uint) byte {
+	if addr < 0x2000 {
+		return CB.ram.Read(addr % 0x0800)
+	} else if addr >= 0x4020 {
+		return CB.mapper.ReadByCpu(addr)
+	}
+	return 0
+}
+
+func (CB *CpuBus) Write(addr uint, data byte) {
+	if addr < 0x2000 {
+		CB.ram.Write(addr%0x0800, data)
+	} else if addr < 0x2008 {
+		// PPU
+		CB.ppu.Write(addr-0x2000, data)
+	} else if addr >= 0x4000 && addr < 0x4020 {
+		if addr == 0x4014 {
+			CB.dma.Write(data)
+		} else if addr == 0x4016 {
+			// INFO: The strobe is wired to both controller ports.
+			CB.keypad1.Write(data)
+			CB.keypad2.Write(data)
+		} else {
+			// NOTE: 0x4017 is the APU frame counter on writes, the second controller on reads.
+			CB.apu.Write(addr-0x4000, data)
+		}
+	} else if addr >= 0x4020 {
+		CB.mapper.WriteByCpu(addr, data)
+	}
+}
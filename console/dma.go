@@ -1,31 +1,25 @@
-package cpu
+package console
 
 import (
-	"github.com/popsul/gones/bus"
 	"github.com/popsul/gones/ppu"
 )
 
+// OAM DMA, the copy itself is done by the CPU (see cpu.DmaBus) since it owns the bus meanwhile.
 type Dma struct {
 	isProcessing bool
 	ramAddr      uint
-	ram          *bus.Ram
 	ppu          *ppu.Ppu
-	addr         uint
-	cycle        uint
 }
 
-func NewDma(ram *bus.Ram, ppu *ppu.Ppu) *Dma {
-	return new(Dma).init(ram, ppu)
+func NewDma(ppu *ppu.Ppu) *Dma {
+	return new(Dma).init(ppu)
 }
 
-func (D *Dma) init(ram *bus.Ram, ppu *ppu.Ppu) *Dma {
+func (D *Dma) init(ppu *ppu.Ppu) *Dma {
 	dma := new(Dma)
 	dma.isProcessing = false
 	dma.ramAddr = 0x0000
-	dma.ram = ram
 	dma.ppu = ppu
-	dma.addr = 0x0000
-	dma.cycle = 0x0000
 	return dma
 }
 
@@ -33,14 +27,15 @@ func (D *Dma) IsDmaProcessing() bool {
 	return D.isProcessing
 }
 
-func (D *Dma) Run() {
-	if !D.isProcessing {
-		return
-	}
+func (D *Dma) Addr() uint {
+	return D.ramAddr
+}
+
+func (D *Dma) Transfer(index uint, data byte) {
+	D.ppu.TransferSprite(index, data)
+}
 
-	for i := uint(0); i < 0x100; i++ {
-		D.ppu.TransferSprite(i, D.ram.Read(D.ramAddr+i))
-	}
+func (D *Dma) Finish() {
 	D.isProcessing = false
 }
 
@@ -0,0 +1,53 @@
+package cpu
+
+/*
+	The core only sees the system through a Bus, every CPU cycle is exactly one Read or Write
+	(dummy accesses included). The optional interfaces are detected when the CPU is created.
+
+	| interface | used for                                                          |
+	+-----------+-------------------------------------------------------------------+
+	| Bus       | memory map, required                                              |
+	| Ticker    | running other chips in step, called once per cycle before access  |
+	| DmaBus    | a DMA unit halting the CPU and copying a page through its reads   |
+*/
+
+type Bus interface {
+	Read(addr uint) byte
+	Write(addr uint, data byte)
+}
+
+type Ticker interface {
+	Tick()
+}
+
+// DmaBus is implemented by buses with an OAM DMA unit, see. Cpu.runDma
+type DmaBus interface {
+	IsDmaProcessing() bool
+	DmaAddr() uint
+	TransferDma(index uint, data byte)
+	FinishDma()
+}
+
+// Memory is a flat 64K RAM bus, to run the core without the rest of the console.
+type Memory struct {
+	data [0x10000]byte
+}
+
+func NewMemory() *Memory {
+	return new(Memory)
+}
+
+func (M *Memory) Read(addr uint) byte {
+	return M.data[addr&0xFFFF]
+}
+
+func (M *Memory) Write(addr uint, data byte) {
+	M.data[addr&0xFFFF] = data
+}
+
+// Load copies data to memory starting at addr, wrapping around at 0xFFFF.
+func (M *Memory) Load(addr uint, data []byte) {
+	for i, b := range data {
+		M.Write(addr+uint(i), b)
+	}
+}
@@ -0,0 +1,394 @@
+package cpu_test
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/popsul/gones/console"
+	"github.com/popsul/gones/cpu"
+	"github.com/popsul/gones/disasm"
+	"github.com/popsul/gones/interrupts"
+	"github.com/popsul/gones/reader"
+	"github.com/popsul/gones/trace"
+)
+
+/*
+	Conformance tests, the original fixtures are not part of the repository (see. testdata/README.md),
+	generated ones are used without them (see. images_test.go). TestInstructions needs no fixture.
+
+	| fixture                    | run                                                     |
+	+----------------------------+---------------------------------------------------------+
+	| (none)                     | one instruction per case from a known state, registers, |
+	|                            | memory and cycles are compared                          |
+	| nestest.nes, nestest.log   | automation mode from $C000, the trace is compared line  |
+	|                            | by line with the log                                    |
+	| 6502_functional_test.bin   | flat 64K RAM from $0400 until a trap (branch or jump to |
+	|                            | itself), success is test_case ($0200) = $F0             |
+*/
+
+const (
+	NESTEST_START      = 0xC000
+	FUNCTIONAL_START   = 0x0400
+	FUNCTIONAL_CASE    = 0x0200
+	FUNCTIONAL_SUCCESS = 0xF0
+	// ~100M cycles are needed, the limit only stops a runaway CPU.
+	FUNCTIONAL_MAX_INSTRUCTIONS = 100000000
+	// Instructions shown before the failing one.
+	HISTORY_SIZE = 16
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if os.IsNotExist(err) {
+		t.Skipf("testdata/%s not found", name)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// nestestFixtures returns nestest.nes and nestest.log, or the generated NROM image and its trace.
+func nestestFixtures(t *testing.T) ([]byte, []byte) {
+	if _, err := os.Stat(filepath.Join("testdata", "nestest.nes")); os.IsNotExist(err) {
+		return newNromImage(nromTraceProgram), readFixture(t, "nrom_trace.log")
+	}
+	return readFixture(t, "nestest.nes"), readFixture(t, "nestest.log")
+}
+
+func TestNestest(t *testing.T) {
+	romData, logData := nestestFixtures(t)
+
+	rom, err := reader.Parse(romData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nes, err := console.NewConsole(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := nes.Cpu()
+	c.SetPC(NESTEST_START)
+
+	var line bytes.Buffer
+	logger := trace.NewLogger(&line, disasm.ReaderFunc(nes.Peek), nes.Ppu().Position)
+	c.SetTracer(logger)
+
+	var history []string
+	scanner := bufio.NewScanner(bytes.NewReader(logData))
+	for number := 1; scanner.Scan(); number++ {
+		expected := strings.TrimRight(scanner.Text(), "\r ")
+		if expected == "" {
+			continue
+		}
+		line.Reset()
+		c.Run()
+		actual := strings.TrimRight(line.String(), "\n")
+		if normalizeTrace(actual) != normalizeTrace(expected) {
+			t.Fatalf(
+				"diverged at nestest.log line %d\n%s\nwant: %s\n got: %s",
+				number,
+				strings.Join(history, "\n"),
+				expected,
+				actual,
+			)
+		}
+		history = appendHistory(history, "      "+actual)
+		if err := c.Jammed(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Official and unofficial opcode results, 0 when every test passed.
+	if result := uint(nes.Peek(0x02)) | uint(nes.Peek(0x03))<<8; result != 0 {
+		t.Errorf("nestest reported error 0x%04x", result)
+	}
+}
+
+// normalizeTrace masks the value read from an I/O register ($2000-$401F), the tracer can't read
+// them without side effects. The rest of the line, effective addresses included, is compared as is.
+func normalizeTrace(line string) string {
+	if len(line) < 48 {
+		return line
+	}
+	instruction := strings.TrimRight(line[16:48], " ")
+	// The value is the last annotation, " = XX", the effective address is the word before it.
+	i := strings.LastIndex(instruction, " = ")
+	if i < 0 || len(instruction)-i != len(" = XX") {
+		return line
+	}
+	words := strings.Fields(instruction[:i])
+	addr, err := strconv.ParseUint(strings.TrimPrefix(words[len(words)-1], "$"), 16, 16)
+	if err != nil || addr < 0x2000 || addr > 0x401F {
+		return line
+	}
+	return line[:16+i] + " = ??" + line[16+len(instruction):]
+}
+
+func TestNormalizeTrace(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{
+			"C054  8D 01 20  STA $2001 = 00                  A:00 X:80 Y:80 P:27 SP:FD PPU:  1,106 CYC:149",
+			"C054  8D 01 20  STA $2001 = ??                  A:00 X:80 Y:80 P:27 SP:FD PPU:  1,106 CYC:149",
+		},
+		{
+			"C02B  BD F0 3F  LDA $3FF0,X @ 4010 = FF         A:00 X:20 Y:90 P:27 SP:FD PPU:  0,210 CYC:70",
+			"C02B  BD F0 3F  LDA $3FF0,X @ 4010 = ??         A:00 X:20 Y:90 P:27 SP:FD PPU:  0,210 CYC:70",
+		},
+		// RAM, the pointer on page $20 of an indirect jump and PRG ROM are not masked.
+		{
+			"C016  B1 10     LDA ($10),Y = 0280 @ 0310 = 5A  A:00 X:00 Y:90 P:26 SP:FD PPU:  0,111 CYC:37",
+			"C016  B1 10     LDA ($10),Y = 0280 @ 0310 = 5A  A:00 X:00 Y:90 P:26 SP:FD PPU:  0,111 CYC:37",
+		},
+		{
+			"C064  6C FF 20  JMP ($20FF) = C06D              A:C0 X:80 Y:80 P:A5 SP:FD PPU:  1,166 CYC:169",
+			"C064  6C FF 20  JMP ($20FF) = C06D              A:C0 X:80 Y:80 P:A5 SP:FD PPU:  1,166 CYC:169",
+		},
+		{
+			"C02B  BD 10 40  LDA $4010,X @ 4030 = FF         A:00 X:20 Y:90 P:27 SP:FD PPU:  0,210 CYC:70",
+			"C02B  BD 10 40  LDA $4010,X @ 4030 = FF         A:00 X:20 Y:90 P:27 SP:FD PPU:  0,210 CYC:70",
+		},
+	}
+	for _, test := range tests {
+		if line := normalizeTrace(test.line); line != test.want {
+			t.Errorf("\n got %q\nwant %q", line, test.want)
+		}
+	}
+}
+
+func appendHistory(history []string, line string) []string {
+	history = append(history, line)
+	if len(history) > HISTORY_SIZE {
+		history = history[1:]
+	}
+	return history
+}
+
+// functionalFixture returns 6502_functional_test.bin, or the generated image.
+func functionalFixture(t *testing.T) []byte {
+	if _, err := os.Stat(filepath.Join("testdata", "6502_functional_test.bin")); os.IsNotExist(err) {
+		return newFunctionalImage(functionalProgram)
+	}
+	if testing.Short() {
+		t.Skip("the functional test runs ~30M instructions")
+	}
+	return readFixture(t, "6502_functional_test.bin")
+}
+
+func TestFunctional(t *testing.T) {
+	image := functionalFixture(t)
+
+	memory := cpu.NewMemory()
+	memory.Load(0x0000, image)
+	c := cpu.NewCpu(memory, interrupts.NewInterrupts())
+	c.PowerOn()
+	c.SetPC(FUNCTIONAL_START)
+
+	var history bytes.Buffer
+	logger := trace.NewLogger(&history, memory, func() (uint, uint) { return 0, 0 })
+	logger.SetRing(HISTORY_SIZE)
+	c.SetTracer(logger)
+
+	for n := 0; n < FUNCTIONAL_MAX_INSTRUCTIONS; n++ {
+		pc := c.Registers().PC
+		c.Run()
+		if c.Registers().PC != pc {
+			continue
+		}
+		if memory.Read(FUNCTIONAL_CASE) == FUNCTIONAL_SUCCESS {
+			return
+		}
+		_ = logger.DumpRing()
+		t.Fatalf(
+			"trapped at $%04x in test case $%02x\n%s",
+			pc,
+			memory.Read(FUNCTIONAL_CASE),
+			history.String(),
+		)
+	}
+	t.Fatalf("no trap after %d instructions, PC: $%04x", FUNCTIONAL_MAX_INSTRUCTIONS, c.Registers().PC)
+}
+
+type cpuState struct {
+	a, x, y, p byte
+}
+
+type instructionTest struct {
+	name string
+	// Address of the instruction, 0 means INSTRUCTION_START.
+	pc     uint
+	code   []byte
+	before cpuState
+	memory map[uint]byte
+	after  cpuState
+	// Stack pointer with the page, 0 means 0x1FD (unchanged).
+	sp         uint
+	nextPC     uint
+	wantMemory map[uint]byte
+	cycles     uint
+}
+
+const (
+	INSTRUCTION_START = 0x0600
+	PRELUDE_START     = 0x0400
+	// B and the unused bit only exist on the stack.
+	STATUS_MASK = 0xCF
+)
+
+/*
+	Flags: N = 0x80, V = 0x40, D = 0x08, I = 0x04, Z = 0x02, C = 0x01
+	Page crossings are from 0x02F0 to 0x0310.
+*/
+
+var instructionTests = []instructionTest{
+	// ADC, SBC: binary only, the NES has no decimal mode
+	{name: "ADC overflow", code: []byte{0x69, 0x50}, before: cpuState{a: 0x50}, after: cpuState{a: 0xA0, p: 0xC0}, nextPC: 0x0602, cycles: 2},
+	{name: "ADC carry and overflow", code: []byte{0x69, 0xD0}, before: cpuState{a: 0x90}, after: cpuState{a: 0x60, p: 0x41}, nextPC: 0x0602, cycles: 2},
+	{name: "ADC zero", code: []byte{0x69, 0x01}, before: cpuState{a: 0xFF}, after: cpuState{a: 0x00, p: 0x03}, nextPC: 0x0602, cycles: 2},
+	{name: "ADC carry in", code: []byte{0x69, 0x00}, before: cpuState{a: 0x7F, p: 0x01}, after: cpuState{a: 0x80, p: 0xC0}, nextPC: 0x0602, cycles: 2},
+	{name: "ADC decimal", code: []byte{0x69, 0x09}, before: cpuState{a: 0x01, p: 0x08}, after: cpuState{a: 0x0A, p: 0x08}, nextPC: 0x0602, cycles: 2},
+	{name: "SBC borrow", code: []byte{0xE9, 0x01}, before: cpuState{a: 0x00, p: 0x01}, after: cpuState{a: 0xFF, p: 0x80}, nextPC: 0x0602, cycles: 2},
+	{name: "SBC overflow", code: []byte{0xE9, 0x01}, before: cpuState{a: 0x80, p: 0x01}, after: cpuState{a: 0x7F, p: 0x41}, nextPC: 0x0602, cycles: 2},
+	{name: "SBC borrow in", code: []byte{0xE9, 0x50}, before: cpuState{a: 0x50}, after: cpuState{a: 0xFF, p: 0x80}, nextPC: 0x0602, cycles: 2},
+	{name: "*SBC", code: []byte{0xEB, 0x10}, before: cpuState{a: 0x20, p: 0x01}, after: cpuState{a: 0x10, p: 0x01}, nextPC: 0x0602, cycles: 2},
+
+	// Compare, BIT
+	{name: "CMP equal", code: []byte{0xC9, 0x40}, before: cpuState{a: 0x40}, after: cpuState{a: 0x40, p: 0x03}, nextPC: 0x0602, cycles: 2},
+	{name: "CPX less", code: []byte{0xE0, 0x41}, before: cpuState{x: 0x40}, after: cpuState{x: 0x40, p: 0x80}, nextPC: 0x0602, cycles: 2},
+	{name: "CPY greater", code: []byte{0xC0, 0x10}, before: cpuState{y: 0x20}, after: cpuState{y: 0x20, p: 0x01}, nextPC: 0x0602, cycles: 2},
+	{name: "BIT", code: []byte{0x24, 0x10}, before: cpuState{a: 0x3F}, memory: map[uint]byte{0x10: 0xC0}, after: cpuState{a: 0x3F, p: 0xC2}, nextPC: 0x0602, cycles: 3},
+
+	// Shifts and read-modify-write
+	{name: "LSR A", code: []byte{0x4A}, before: cpuState{a: 0x01}, after: cpuState{a: 0x00, p: 0x03}, nextPC: 0x0601, cycles: 2},
+	{name: "ROL A", code: []byte{0x2A}, before: cpuState{a: 0x80, p: 0x01}, after: cpuState{a: 0x01, p: 0x01}, nextPC: 0x0601, cycles: 2},
+	{name: "ASL zp", code: []byte{0x06, 0x10}, memory: map[uint]byte{0x10: 0x81}, after: cpuState{p: 0x01}, nextPC: 0x0602, wantMemory: map[uint]byte{0x10: 0x02}, cycles: 5},
+	{name: "ROR abs", code: []byte{0x6E, 0x00, 0x03}, memory: map[uint]byte{0x0300: 0x01}, after: cpuState{p: 0x03}, nextPC: 0x0603, wantMemory: map[uint]byte{0x0300: 0x00}, cycles: 6},
+	{name: "ASL abs,X", code: []byte{0x1E, 0x00, 0x03}, before: cpuState{x: 0x01}, memory: map[uint]byte{0x0301: 0x40}, after: cpuState{x: 0x01, p: 0x80}, nextPC: 0x0603, wantMemory: map[uint]byte{0x0301: 0x80}, cycles: 7},
+	{name: "INC abs,X", code: []byte{0xFE, 0x00, 0x03}, before: cpuState{x: 0x01}, memory: map[uint]byte{0x0301: 0xFF}, after: cpuState{x: 0x01, p: 0x02}, nextPC: 0x0603, wantMemory: map[uint]byte{0x0301: 0x00}, cycles: 7},
+	{name: "DEC zp,X wraps in page 0", code: []byte{0xD6, 0xF0}, before: cpuState{x: 0x20}, memory: map[uint]byte{0x10: 0x01}, after: cpuState{x: 0x20, p: 0x02}, nextPC: 0x0602, wantMemory: map[uint]byte{0x10: 0x00}, cycles: 6},
+
+	// Addressing modes and page crossings
+	{name: "LDA abs,X", code: []byte{0xBD, 0x00, 0x03}, before: cpuState{x: 0x10}, memory: map[uint]byte{0x0310: 0x80}, after: cpuState{a: 0x80, x: 0x10, p: 0x80}, nextPC: 0x0603, cycles: 4},
+	{name: "LDA abs,X page crossing", code: []byte{0xBD, 0xF0, 0x02}, before: cpuState{x: 0x20}, memory: map[uint]byte{0x0310: 0x01}, after: cpuState{a: 0x01, x: 0x20}, nextPC: 0x0603, cycles: 5},
+	{name: "LDA (zp),Y", code: []byte{0xB1, 0x10}, memory: map[uint]byte{0x10: 0xF0, 0x11: 0x02, 0x02F0: 0x01}, after: cpuState{a: 0x01}, nextPC: 0x0602, cycles: 5},
+	{name: "LDA (zp),Y page crossing", code: []byte{0xB1, 0x10}, before: cpuState{y: 0x20}, memory: map[uint]byte{0x10: 0xF0, 0x11: 0x02, 0x0310: 0x01}, after: cpuState{a: 0x01, y: 0x20}, nextPC: 0x0602, cycles: 6},
+	{name: "LDA (zp,X) pointer wraps in page 0", code: []byte{0xA1, 0xFE}, before: cpuState{x: 0x01}, memory: map[uint]byte{0xFF: 0x10, 0x00: 0x03, 0x0310: 0x01}, after: cpuState{a: 0x01, x: 0x01}, nextPC: 0x0602, cycles: 6},
+	{name: "STA abs,X", code: []byte{0x9D, 0x00, 0x03}, before: cpuState{a: 0x42, x: 0x01}, after: cpuState{a: 0x42, x: 0x01}, nextPC: 0x0603, wantMemory: map[uint]byte{0x0301: 0x42}, cycles: 5},
+	{name: "STA abs,Y", code: []byte{0x99, 0x00, 0x03}, before: cpuState{a: 0x42, y: 0x10}, after: cpuState{a: 0x42, y: 0x10}, nextPC: 0x0603, wantMemory: map[uint]byte{0x0310: 0x42}, cycles: 5},
+	{name: "STA (zp),Y", code: []byte{0x91, 0x10}, before: cpuState{a: 0x42}, memory: map[uint]byte{0x10: 0x00, 0x11: 0x03}, after: cpuState{a: 0x42}, nextPC: 0x0602, wantMemory: map[uint]byte{0x0300: 0x42}, cycles: 6},
+
+	// Jumps, branches and the stack
+	{name: "JMP (ind) page bug", code: []byte{0x6C, 0xFF, 0x02}, memory: map[uint]byte{0x02FF: 0x00, 0x0200: 0x07, 0x0300: 0x08}, nextPC: 0x0700, cycles: 5},
+	{name: "BNE not taken", code: []byte{0xD0, 0x10}, before: cpuState{p: 0x02}, after: cpuState{p: 0x02}, nextPC: 0x0602, cycles: 2},
+	{name: "BNE taken", code: []byte{0xD0, 0x10}, nextPC: 0x0612, cycles: 3},
+	{name: "BNE taken page crossing", pc: 0x06F0, code: []byte{0xD0, 0x20}, nextPC: 0x0712, cycles: 4},
+	{name: "BEQ backward page crossing", code: []byte{0xF0, 0xFC}, before: cpuState{p: 0x02}, after: cpuState{p: 0x02}, nextPC: 0x05FE, cycles: 4},
+	{name: "JSR", code: []byte{0x20, 0x00, 0x07}, nextPC: 0x0700, sp: 0x1FB, wantMemory: map[uint]byte{0x01FD: 0x06, 0x01FC: 0x02}, cycles: 6},
+	{name: "RTS", code: []byte{0x60}, memory: map[uint]byte{0x01FE: 0x02, 0x01FF: 0x07}, nextPC: 0x0703, sp: 0x1FF, cycles: 6},
+	{name: "RTI wraps the stack", code: []byte{0x40}, memory: map[uint]byte{0x01FE: 0xC3, 0x01FF: 0x00, 0x0100: 0x07}, after: cpuState{p: 0xC3}, nextPC: 0x0700, sp: 0x100, cycles: 6},
+	{name: "BRK", code: []byte{0x00, 0xFF}, before: cpuState{p: 0x01}, memory: map[uint]byte{0xFFFE: 0x00, 0xFFFF: 0x07}, after: cpuState{p: 0x05}, nextPC: 0x0700, sp: 0x1FA, wantMemory: map[uint]byte{0x01FD: 0x06, 0x01FC: 0x02, 0x01FB: 0x31}, cycles: 7},
+	{name: "PHP sets B", code: []byte{0x08}, before: cpuState{p: 0xC3}, after: cpuState{p: 0xC3}, nextPC: 0x0601, sp: 0x1FC, wantMemory: map[uint]byte{0x01FD: 0xF3}, cycles: 3},
+	{name: "PLA", code: []byte{0x68}, memory: map[uint]byte{0x01FE: 0x80}, after: cpuState{a: 0x80, p: 0x80}, nextPC: 0x0601, sp: 0x1FE, cycles: 4},
+	{name: "TSX", code: []byte{0xBA}, after: cpuState{x: 0xFD, p: 0x80}, nextPC: 0x0601, cycles: 2},
+	{name: "TXS keeps flags", code: []byte{0x9A}, before: cpuState{p: 0x01}, after: cpuState{p: 0x01}, nextPC: 0x0601, sp: 0x100, cycles: 2},
+
+	// Registers and flags
+	{name: "DEX", code: []byte{0xCA}, after: cpuState{x: 0xFF, p: 0x80}, nextPC: 0x0601, cycles: 2},
+	{name: "INY", code: []byte{0xC8}, before: cpuState{y: 0x7F}, after: cpuState{y: 0x80, p: 0x80}, nextPC: 0x0601, cycles: 2},
+	{name: "CLV", code: []byte{0xB8}, before: cpuState{p: 0x41}, after: cpuState{p: 0x01}, nextPC: 0x0601, cycles: 2},
+	{name: "NOP", code: []byte{0xEA}, nextPC: 0x0601, cycles: 2},
+
+	// Unofficial opcodes
+	{name: "*LAX zp", code: []byte{0xA7, 0x10}, memory: map[uint]byte{0x10: 0x8F}, after: cpuState{a: 0x8F, x: 0x8F, p: 0x80}, nextPC: 0x0602, cycles: 3},
+	{name: "*SAX zp", code: []byte{0x87, 0x10}, before: cpuState{a: 0xF0, x: 0x3C}, after: cpuState{a: 0xF0, x: 0x3C}, nextPC: 0x0602, wantMemory: map[uint]byte{0x10: 0x30}, cycles: 3},
+	{name: "*DCP zp", code: []byte{0xC7, 0x10}, before: cpuState{a: 0x40}, memory: map[uint]byte{0x10: 0x41}, after: cpuState{a: 0x40, p: 0x03}, nextPC: 0x0602, wantMemory: map[uint]byte{0x10: 0x40}, cycles: 5},
+	{name: "*ISB zp", code: []byte{0xE7, 0x10}, before: cpuState{a: 0x20, p: 0x01}, memory: map[uint]byte{0x10: 0x0F}, after: cpuState{a: 0x10, p: 0x01}, nextPC: 0x0602, wantMemory: map[uint]byte{0x10: 0x10}, cycles: 5},
+	{name: "*ISB zp overflow", code: []byte{0xE7, 0x10}, before: cpuState{a: 0x80, p: 0x01}, memory: map[uint]byte{0x10: 0x00}, after: cpuState{a: 0x7F, p: 0x41}, nextPC: 0x0602, wantMemory: map[uint]byte{0x10: 0x01}, cycles: 5},
+	{name: "*SLO zp", code: []byte{0x07, 0x10}, before: cpuState{a: 0x01}, memory: map[uint]byte{0x10: 0x81}, after: cpuState{a: 0x03, p: 0x01}, nextPC: 0x0602, wantMemory: map[uint]byte{0x10: 0x02}, cycles: 5},
+	{name: "*RLA zp", code: []byte{0x27, 0x10}, before: cpuState{a: 0xFF, p: 0x01}, memory: map[uint]byte{0x10: 0x80}, after: cpuState{a: 0x01, p: 0x01}, nextPC: 0x0602, wantMemory: map[uint]byte{0x10: 0x01}, cycles: 5},
+	{name: "*SRE zp", code: []byte{0x47, 0x10}, before: cpuState{a: 0xFF}, memory: map[uint]byte{0x10: 0x03}, after: cpuState{a: 0xFE, p: 0x81}, nextPC: 0x0602, wantMemory: map[uint]byte{0x10: 0x01}, cycles: 5},
+	{name: "*RRA zp", code: []byte{0x67, 0x10}, before: cpuState{a: 0x10, p: 0x01}, memory: map[uint]byte{0x10: 0x02}, after: cpuState{a: 0x91, p: 0x80}, nextPC: 0x0602, wantMemory: map[uint]byte{0x10: 0x81}, cycles: 5},
+	{name: "*RLA abs,X", code: []byte{0x3F, 0x00, 0x03}, before: cpuState{a: 0xFF, x: 0x01}, memory: map[uint]byte{0x0301: 0x01}, after: cpuState{a: 0x02, x: 0x01}, nextPC: 0x0603, wantMemory: map[uint]byte{0x0301: 0x02}, cycles: 7},
+	{name: "*ANC", code: []byte{0x0B, 0x80}, before: cpuState{a: 0xFF}, after: cpuState{a: 0x80, p: 0x81}, nextPC: 0x0602, cycles: 2},
+	{name: "*ALR", code: []byte{0x4B, 0x03}, before: cpuState{a: 0xFF}, after: cpuState{a: 0x01, p: 0x01}, nextPC: 0x0602, cycles: 2},
+	{name: "*ARR", code: []byte{0x6B, 0xFF}, before: cpuState{a: 0xC0, p: 0x01}, after: cpuState{a: 0xE0, p: 0x81}, nextPC: 0x0602, cycles: 2},
+	{name: "*AXS", code: []byte{0xCB, 0x10}, before: cpuState{a: 0xF0, x: 0x3C}, after: cpuState{a: 0xF0, x: 0x20, p: 0x01}, nextPC: 0x0602, cycles: 2},
+	{name: "*NOP imm", code: []byte{0x80, 0xFF}, nextPC: 0x0602, cycles: 2},
+	{name: "*NOP abs,X page crossing", code: []byte{0x1C, 0xF0, 0x02}, before: cpuState{x: 0x20}, after: cpuState{x: 0x20}, nextPC: 0x0603, cycles: 5},
+}
+
+// prelude sets the registers from state and jumps to the instruction.
+func prelude(state cpuState, pc uint) []byte {
+	return []byte{
+		0xA2, state.x, // LDX #x
+		0xA0, state.y, // LDY #y
+		0xA9, state.p, // LDA #p
+		0x48,          // PHA
+		0xA9, state.a, // LDA #a
+		0x28,                          // PLP
+		0x4C, byte(pc), byte(pc >> 8), // JMP pc
+	}
+}
+
+const PRELUDE_INSTRUCTIONS = 7
+
+func TestInstructions(t *testing.T) {
+	for _, test := range instructionTests {
+		pc := test.pc
+		if pc == 0 {
+			pc = INSTRUCTION_START
+		}
+		sp := test.sp
+		if sp == 0 {
+			sp = 0x1FD
+		}
+
+		memory := cpu.NewMemory()
+		for addr, data := range test.memory {
+			memory.Write(addr, data)
+		}
+		memory.Load(PRELUDE_START, prelude(test.before, pc))
+		memory.Load(pc, test.code)
+		c := cpu.NewCpu(memory, interrupts.NewInterrupts())
+		c.PowerOn()
+		c.SetPC(PRELUDE_START)
+		for i := 0; i < PRELUDE_INSTRUCTIONS; i++ {
+			c.Run()
+		}
+
+		cycles := c.Run()
+		registers := c.Registers()
+		actual := cpuState{
+			a: byte(registers.A),
+			x: byte(registers.X),
+			y: byte(registers.Y),
+			p: registers.P.Byte() & STATUS_MASK,
+		}
+		if actual != test.after {
+			t.Errorf("%s: A:%02X X:%02X Y:%02X P:%02X, want A:%02X X:%02X Y:%02X P:%02X", test.name,
+				actual.a, actual.x, actual.y, actual.p, test.after.a, test.after.x, test.after.y, test.after.p)
+		}
+		if registers.PC != test.nextPC {
+			t.Errorf("%s: PC $%04X, want $%04X", test.name, registers.PC, test.nextPC)
+		}
+		if 0x100|registers.SP&0xFF != sp {
+			t.Errorf("%s: SP $%02X, want $%02X", test.name, registers.SP&0xFF, sp&0xFF)
+		}
+		for addr, data := range test.wantMemory {
+			if actual := memory.Read(addr); actual != data {
+				t.Errorf("%s: $%04X = $%02X, want $%02X", test.name, addr, actual, data)
+			}
+		}
+		if cycles != test.cycles {
+			t.Errorf("%s: %d cycles, want %d", test.name, cycles, test.cycles)
+		}
+	}
+}
@@ -7,40 +7,104 @@ import (
 	"github.com/popsul/gones/interrupts"
 )
 
-var instrNumber = 0
+/*
+	Every bus access takes one CPU cycle and ticks the PPU and the APU before it lands,
+	so the number of cycles an instruction takes is the number of its bus accesses.
+	The 6502 never idles, cycles without useful work read (or write) some address anyway:
+
+	| cycle            | access                                                  |
+	+------------------+---------------------------------------------------------+
+	| implied          | reads the byte after the opcode                         |
+	| zp,X  (ind,X)    | reads the unindexed zero page address                   |
+	| abs,X abs,Y      | reads the address before the high byte is fixed up,     |
+	| (ind),Y          | only on page crossing for reads, always for writes      |
+	| read-modify-write| writes the unmodified value back before the result      |
+	| stack pull       | reads the stack before incrementing SP                  |
+	| taken branch     | reads the next opcode (and the unfixed target)          |
+
+	see. http://nesdev.com/6502_cpu.txt
+*/
 
 type Cpu struct {
-	bus         *CpuBus
-	interrupts  *interrupts.Interrupts
-	registers   *Registers
-	hasBranched bool
+	bus        Bus
+	ticker     Ticker
+	dma        DmaBus
+	interrupts *interrupts.Interrupts
+	registers  *Registers
+	cycles     uint64
+	jammed     *JamError
+	tracer     Tracer
+	// Interrupt polling, see. pollInterrupts
+	nmiLine     bool
+	needNmi     bool
+	previousNmi bool
+	needIrq     bool
+	previousIrq bool
 }
 
-type AddrOrDataAndAdditionalCycle struct {
-	addrOrData, additionalCycle uint
+// JamError is the state of a CPU halted by a JAM (KIL) opcode, only a reset recovers it.
+type JamError struct {
+	OpCode    uint
+	Registers Registers
 }
 
-func newAddrOrDataAndAdditionalCycle(addrOrData uint, additionalCycle uint) AddrOrDataAndAdditionalCycle {
-	return AddrOrDataAndAdditionalCycle{
-		addrOrData,
-		additionalCycle,
-	}
+func (E *JamError) Error() string {
+	return fmt.Sprintf(
+		"CPU jammed by opcode 0x%02x at PC: 0x%04x A: 0x%02x X: 0x%02x Y: 0x%02x SP: 0x%04x",
+		E.OpCode,
+		E.Registers.PC,
+		E.Registers.A,
+		E.Registers.X,
+		E.Registers.Y,
+		E.Registers.SP,
+	)
 }
 
-func NewCpu(bus *CpuBus, interrupts *interrupts.Interrupts) *Cpu {
+func NewCpu(bus Bus, interrupts *interrupts.Interrupts) *Cpu {
 	cpu := new(Cpu)
 	cpu.bus = bus
+	cpu.ticker, _ = bus.(Ticker)
+	cpu.dma, _ = bus.(DmaBus)
 	cpu.interrupts = interrupts
 	cpu.registers = NewRegisters()
-	cpu.hasBranched = false
 	return cpu
 }
 
-func (C *Cpu) Reset() {
+/*
+	Power on and reset
+	see. https://wiki.nesdev.com/w/index.php/CPU_power_up_state
+
+	Both run the interrupt sequence with the writes turned into reads, so SP is decremented
+	by 3 and nothing is pushed. Power on starts from A, X, Y = 0 and SP = 0x00 (0xFD after
+	the sequence), reset keeps A, X, Y and the flags except I.
+*/
+
+func (C *Cpu) PowerOn() {
 	C.registers = NewRegisters()
+	C.registers.SP = 0x0100
+	C.reset()
+}
+
+func (C *Cpu) Reset() {
+	C.reset()
+}
+
+func (C *Cpu) reset() {
+	C.jammed = nil
+	C.nmiLine = false
+	C.needNmi = false
+	C.previousNmi = false
+	C.needIrq = false
+	C.previousIrq = false
+	C.dummyRead(C.registers.PC)
+	C.dummyRead(C.registers.PC)
+	for i := 0; i < 3; i++ {
+		C.dummyRead(C.stackAddr())
+		C.registers.SP = 0x100 | ((C.registers.SP - 1) & 0xFF)
+	}
+	C.registers.P.Interrupt = true
 	// TODO: flownes set 0x8000 to PC when read(0xfffc) fails.
 	C.registers.PC = uint(C.Read(0xfffc, true))
-	fmt.Printf("Initial pc: %04x\n", C.registers.PC)
 }
 
 func (C *Cpu) Fetch(addr uint, asWord bool) uint {
@@ -55,13 +119,96 @@ func (C *Cpu) Fetch(addr uint, asWord bool) uint {
 func (C *Cpu) Read(addr uint, asWord bool) uint {
 	addr &= 0xFFFF
 	if asWord {
-		return uint(C.bus.ReadByCpu(addr)) | (uint(C.bus.ReadByCpu(addr+1)) << 8)
+		low := C.Read(addr, false)
+		return low | C.Read(addr+1, false)<<8
 	}
-	return uint(C.bus.ReadByCpu(addr))
+	C.tick()
+	return uint(C.bus.Read(addr))
 }
 
 func (C *Cpu) Write(addr uint, data byte) {
-	C.bus.WriteByCpu(addr, data)
+	C.tick()
+	C.bus.Write(addr, data)
+}
+
+func (C *Cpu) tick() {
+	C.cycles++
+	if C.ticker != nil {
+		C.ticker.Tick()
+	}
+	C.pollInterrupts()
+}
+
+/*
+	Interrupt polling
+	see. https://wiki.nesdev.com/w/index.php/CPU_interrupts
+
+	The lines are sampled at the end of every cycle, but an instruction only looks at the state
+	sampled at the end of its penultimate cycle. So an interrupt asserted during the last cycle
+	waits for one more instruction, and CLI, SEI and PLP affect the poll one instruction late.
+	NMI is latched on the rising edge of the line and stays pending until serviced.
+*/
+
+func (C *Cpu) pollInterrupts() {
+	C.previousNmi = C.needNmi
+	nmiLine := C.interrupts.IsNmiAssert()
+	if nmiLine && !C.nmiLine {
+		C.needNmi = true
+	}
+	C.nmiLine = nmiLine
+
+	C.previousIrq = C.needIrq
+	C.needIrq = C.interrupts.IsIrqAssert() && !C.registers.P.Interrupt
+}
+
+// Jammed returns a *JamError when the CPU is halted, nil otherwise.
+func (C *Cpu) Jammed() error {
+	if C.jammed == nil {
+		return nil
+	}
+	return C.jammed
+}
+
+// Tracer is called before each instruction is fetched (not for interrupts or DMA).
+type Tracer interface {
+	Trace(C *Cpu)
+}
+
+// SetTracer enables tracing, nil disables it.
+func (C *Cpu) SetTracer(tracer Tracer) {
+	C.tracer = tracer
+}
+
+// SetPC jumps to addr, e.g. to start a test ROM in its automation mode.
+func (C *Cpu) SetPC(addr uint) {
+	C.registers.PC = addr & 0xFFFF
+}
+
+// Registers returns a copy of the registers.
+func (C *Cpu) Registers() Registers {
+	registers := *C.registers
+	status := *C.registers.P
+	registers.P = &status
+	return registers
+}
+
+// Cycles returns the number of CPU cycles since power on.
+func (C *Cpu) Cycles() uint64 {
+	return C.cycles
+}
+
+func (C *Cpu) dummyRead(addr uint) {
+	C.Read(addr, false)
+}
+
+func (C *Cpu) readModify(addr uint) uint {
+	data := C.Read(addr, false)
+	C.Write(addr, byte(data))
+	return data
+}
+
+func (C *Cpu) stackAddr() uint {
+	return 0x100 | (C.registers.SP & 0xFF)
 }
 
 func (C *Cpu) Push(data byte) {
@@ -75,20 +222,15 @@ func (C *Cpu) Pop() uint {
 }
 
 func (C *Cpu) Branch(addr uint) {
+	C.dummyRead(C.registers.PC)
+	if (addr & 0xFF00) != (C.registers.PC & 0xFF00) {
+		C.dummyRead((C.registers.PC & 0xFF00) | (addr & 0xFF))
+	}
 	C.registers.PC = addr
-	C.hasBranched = true
 }
 
 func (C *Cpu) pushStatus() {
-	status := byte(B2i(C.registers.P.Negative)<<7 |
-		B2i(C.registers.P.Overflow)<<6 |
-		B2i(C.registers.P.Reserved)<<5 |
-		B2i(C.registers.P.BreakMode)<<4 |
-		B2i(C.registers.P.DecimalMode)<<3 |
-		B2i(C.registers.P.Interrupt)<<2 |
-		B2i(C.registers.P.Zero)<<1 |
-		B2i(C.registers.P.Carry))
-	C.Push(status)
+	C.Push(C.registers.P.Byte())
 }
 
 func (C *Cpu) popStatus() {
@@ -109,604 +251,138 @@ func (C *Cpu) PopPC() {
 }
 
 func (C *Cpu) ProcessNmi() {
-	C.interrupts.ReleaseNmi()
-	C.registers.P.BreakMode = false
-	C.Push(byte((C.registers.PC >> 8) & 0xFF))
-	C.Push(byte(C.registers.PC & 0xFF))
-	C.pushStatus()
-	C.registers.P.Interrupt = true
-	C.registers.PC = C.Read(0xFFFA, true)
+	C.dummyRead(C.registers.PC)
+	C.dummyRead(C.registers.PC)
+	C.interrupt(0xFFFA, false)
 }
 
+// The IRQ line is level triggered, the sources are released by the program acknowledging them.
 func (C *Cpu) processIrq() {
-	if C.registers.P.Interrupt {
-		return
-	}
-	C.interrupts.ReleaseIrq()
-	C.registers.P.BreakMode = false
+	C.dummyRead(C.registers.PC)
+	C.dummyRead(C.registers.PC)
+	C.interrupt(0xFFFE, false)
+}
+
+// Pushes PC and P, then jumps through the vector. Shared by NMI, IRQ and BRK.
+// NOTE: An NMI detected before P is pushed hijacks the sequence, it continues with the NMI vector
+// and the IRQ (or BRK) is lost. BRK still pushes P with the B flag set.
+func (C *Cpu) interrupt(vector uint, isBreak bool) {
 	C.Push(byte((C.registers.PC >> 8) & 0xFF))
 	C.Push(byte(C.registers.PC & 0xFF))
+	if C.needNmi {
+		C.needNmi = false
+		vector = 0xFFFA
+	}
+	C.registers.P.BreakMode = isBreak
 	C.pushStatus()
 	C.registers.P.Interrupt = true
-	C.registers.PC = C.Read(0xFFFE, false)
+	C.registers.PC = C.Read(vector, true)
+	// The first instruction of the handler always runs before the next interrupt.
+	C.previousNmi = false
+	C.previousIrq = false
 }
 
-func (C *Cpu) getAddrOrDataWithAdditionalCycle(mode Addressing) AddrOrDataAndAdditionalCycle {
+func (C *Cpu) getAddrOrData(mode Addressing, isWrite bool) uint {
 	switch mode {
-	case Accumulator:
-		return newAddrOrDataAndAdditionalCycle(0x00, 0)
-	case Implied:
-		return newAddrOrDataAndAdditionalCycle(0x00, 0)
+	case Accumulator, Implied:
+		C.dummyRead(C.registers.PC)
+		return 0x00
 	case Immediate:
-		return newAddrOrDataAndAdditionalCycle(C.Fetch(C.registers.PC, false), 0)
+		return C.Fetch(C.registers.PC, false)
 	case Relative:
 		baseAddr := C.Fetch(C.registers.PC, false)
-		var addr uint
 		if baseAddr < 0x80 {
-			addr = baseAddr + C.registers.PC
-		} else {
-			addr = baseAddr + C.registers.PC - 256
+			return (baseAddr + C.registers.PC) & 0xFFFF
 		}
-		return newAddrOrDataAndAdditionalCycle(
-			addr,
-			B2i((addr&0xff00) != (C.registers.PC&0xFF00)),
-		)
+		return (baseAddr + C.registers.PC - 256) & 0xFFFF
 	case ZeroPage:
-		return newAddrOrDataAndAdditionalCycle(C.Fetch(C.registers.PC, false), 0)
+		return C.Fetch(C.registers.PC, false)
 	case ZeroPageX:
 		addr := C.Fetch(C.registers.PC, false)
-		return newAddrOrDataAndAdditionalCycle(
-			(addr+C.registers.X)&0xff,
-			0,
-		)
+		C.dummyRead(addr)
+		return (addr + C.registers.X) & 0xFF
 	case ZeroPageY:
 		addr := C.Fetch(C.registers.PC, false)
-		return newAddrOrDataAndAdditionalCycle(addr+C.registers.Y&0xff, 0)
+		C.dummyRead(addr)
+		return (addr + C.registers.Y) & 0xFF
 	case Absolute:
-		return newAddrOrDataAndAdditionalCycle(C.Fetch(C.registers.PC, true), 0)
+		return C.Fetch(C.registers.PC, true)
 	case AbsoluteX:
-		addr := C.Fetch(C.registers.PC, true)
-		additionalCycle := B2i((addr & 0xFF00) != ((addr + C.registers.X) & 0xFF00))
-		return newAddrOrDataAndAdditionalCycle((addr+C.registers.X)&0xFFFF, additionalCycle)
+		return C.indexed(C.Fetch(C.registers.PC, true), C.registers.X, isWrite)
 	case AbsoluteY:
-		addr := C.Fetch(C.registers.PC, true)
-		additionalCycle := B2i((addr & 0xFF00) != ((addr + C.registers.Y) & 0xFF00))
-		return newAddrOrDataAndAdditionalCycle((addr+C.registers.Y)&0xFFFF, additionalCycle)
+		return C.indexed(C.Fetch(C.registers.PC, true), C.registers.Y, isWrite)
 	case PreIndexedIndirect:
-		baseAddr := (C.Fetch(C.registers.PC, false) + C.registers.X) & 0xFF
-		addr := C.Read(baseAddr, false) + (C.Read((baseAddr+1)&0xFF, false) << 8)
-		return newAddrOrDataAndAdditionalCycle(
-			addr&0xFFFF,
-			B2i((addr&0xFF00) != (baseAddr&0xFF00)),
-		)
+		addrOrData := C.Fetch(C.registers.PC, false)
+		C.dummyRead(addrOrData)
+		baseAddr := (addrOrData + C.registers.X) & 0xFF
+		return C.Read(baseAddr, false) + (C.Read((baseAddr+1)&0xFF, false) << 8)
 	case PostIndexedIndirect:
 		addrOrData := C.Fetch(C.registers.PC, false)
 		baseAddr := C.Read(addrOrData, false) + (C.Read((addrOrData+1)&0xFF, false) << 8)
-		addr := baseAddr + C.registers.Y
-		return newAddrOrDataAndAdditionalCycle(
-			addr&0xFFFF,
-			B2i((addr&0xFF00) != (baseAddr&0xFF00)),
-		)
+		return C.indexed(baseAddr, C.registers.Y, isWrite)
 	case IndirectAbsolute:
 		addrOrData := C.Fetch(C.registers.PC, true)
 		addr := C.Read(addrOrData, false) +
 			(C.Read((addrOrData&0xFF00)|(((addrOrData&0xFF)+1)&0xFF), false) << 8)
-		return newAddrOrDataAndAdditionalCycle(addr&0xFFFF, 0)
+		return addr & 0xFFFF
 	default:
 		fmt.Printf("Mode: %d\n", mode)
 		panic(errors.New("Unknown addressing mode detected."))
 	}
 }
 
-func (C *Cpu) dumpRegisters() {
-	fmt.Printf(
-		"R: 0b%d%d%d%d%d%d%d%d SP: 0x%04x PC: 0x%04x A: 0x%02x X: 0x%02x Y: 0x%02x\n",
-		B2i(C.registers.P.DecimalMode),
-		B2i(C.registers.P.Zero),
-		B2i(C.registers.P.Negative),
-		B2i(C.registers.P.Interrupt),
-		B2i(C.registers.P.BreakMode),
-		B2i(C.registers.P.Carry),
-		B2i(C.registers.P.Overflow),
-		B2i(C.registers.P.Reserved),
-		C.registers.SP,
-		C.registers.PC,
-		C.registers.A,
-		C.registers.X,
-		C.registers.Y,
-	)
-}
-
-func (C *Cpu) execOpCode(op uint, dataInfo AddrOrDataAndAdditionalCycle) {
-	opInfo := OpCodes[op]
-	addrOrData := dataInfo.addrOrData
-	mode := opInfo.Addressing
-	instrNumber++
-	//if instrNumber > 0 {
-	//	fmt.Printf(
-	//		"%d (%s) ADDR: 0x%04x (%s) ",
-	//		instrNumber,
-	//		opInfo.BaseName,
-	//		dataInfo.addrOrData,
-	//		AddressingNameShort[opInfo.Addressing],
-	//	)
-	//
-	//	C.dumpRegisters()
-	//}
-
-	C.hasBranched = false
-	var tmpData uint
-	switch opInfo.BaseName {
-	case "LDA":
-		data := C.Read(addrOrData, false)
-		C.registers.A = B2ix(mode == Immediate, addrOrData, data)
-		//fmt.Printf("LDA 0x%04x 0x%04x 0x%04x\n", addrOrData, C.registers.A, data)
-		C.registers.P.Negative = I2b(C.registers.A & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.A)
-		break
-	case "LDX":
-		if mode == Immediate {
-			tmpData = addrOrData
-		} else {
-			tmpData = C.Read(addrOrData, false)
-		}
-		C.registers.X = tmpData
-		C.registers.P.Negative = I2b(C.registers.X & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.X)
-		break
-	case "LDY":
-		if mode == Immediate {
-			tmpData = addrOrData
-		} else {
-			tmpData = C.Read(addrOrData, false)
-		}
-		C.registers.Y = tmpData
-		C.registers.P.Negative = I2b(C.registers.Y & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.Y)
-		break
-	case "STA":
-		//fmt.Printf("STA 0x%04x 0x%02x\n", addrOrData, C.registers.A)
-		C.Write(addrOrData, byte(C.registers.A))
-		break
-	case "STX":
-		C.Write(addrOrData, byte(C.registers.X))
-		break
-	case "STY":
-		C.Write(addrOrData, byte(C.registers.Y))
-		break
-	case "TAX":
-		C.registers.X = C.registers.A
-		C.registers.P.Negative = I2b(C.registers.X & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.X)
-		break
-	case "TAY":
-		C.registers.Y = C.registers.A
-		C.registers.P.Negative = I2b(C.registers.Y & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.Y)
-		break
-	case "TSX":
-		C.registers.X = C.registers.SP & 0xFF
-		C.registers.P.Negative = I2b(C.registers.X & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.X)
-		break
-	case "TXA":
-		C.registers.A = C.registers.X
-		C.registers.P.Negative = I2b(C.registers.A & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.A)
-		break
-	case "TXS":
-		C.registers.SP = C.registers.X + 0x0100
-		break
-	case "TYA":
-		C.registers.A = C.registers.Y
-		C.registers.P.Negative = I2b(C.registers.A & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.A)
-		break
-	case "ADC":
-		if mode == Immediate {
-			tmpData = addrOrData
-		} else {
-			tmpData = C.Read(addrOrData, false)
-		}
-		operated := tmpData + C.registers.A + B2i(C.registers.P.Carry)
-		overflow := !(((C.registers.A ^ tmpData) & 0x80) != 0) && ((C.registers.A^operated)&0x80) != 0
-		C.registers.P.Overflow = overflow
-		C.registers.P.Carry = operated > 0xFF
-		C.registers.P.Negative = I2b(operated & 0x80)
-		C.registers.P.Zero = !I2b(operated & 0xFF)
-		C.registers.A = operated & 0xFF
-		break
-	case "AND":
-		if mode == Immediate {
-			tmpData = addrOrData
-		} else {
-			tmpData = C.Read(addrOrData, false)
-		}
-		operated := tmpData & C.registers.A
-		C.registers.P.Negative = I2b(operated & 0x80)
-		C.registers.P.Zero = !I2b(operated)
-		C.registers.A = operated & 0xFF
-		break
-	case "ASL":
-		if mode == Accumulator {
-			acc := C.registers.A
-			C.registers.P.Carry = !!I2b(acc & 0x80)
-			C.registers.A = (acc << 1) & 0xFF
-			C.registers.P.Zero = !I2b(C.registers.A)
-			C.registers.P.Negative = I2b(C.registers.A & 0x80)
-		} else {
-			data := C.Read(addrOrData, false)
-			C.registers.P.Carry = I2b(data & 0x80)
-			shifted := (data << 1) & 0xFF
-			C.Write(addrOrData, byte(shifted))
-			C.registers.P.Zero = !I2b(shifted)
-			C.registers.P.Negative = I2b(shifted & 0x80)
-		}
-		break
-	case "BIT":
-		data := C.Read(addrOrData, false)
-		C.registers.P.Negative = I2b(data & 0x80)
-		C.registers.P.Overflow = I2b(data & 0x40)
-		C.registers.P.Zero = !I2b(C.registers.A & data)
-		break
-	case "CMP":
-		if mode == Immediate {
-			tmpData = addrOrData
-		} else {
-			tmpData = C.Read(addrOrData, false)
-		}
-		compared := int(C.registers.A) - int(tmpData)
-		C.registers.P.Carry = compared >= 0
-		C.registers.P.Negative = I2b(uint(compared & 0x80))
-		C.registers.P.Zero = !I2b(uint(compared & 0xff))
-		break
-	case "CPX":
-		if mode == Immediate {
-			tmpData = addrOrData
-		} else {
-			tmpData = C.Read(addrOrData, false)
-		}
-		compared := int(C.registers.X) - int(tmpData)
-		C.registers.P.Carry = compared >= 0
-		C.registers.P.Negative = I2b(uint(compared) & 0x80)
-		C.registers.P.Zero = !I2b(uint(compared) & 0xff)
-		break
-	case "CPY":
-		if mode == Immediate {
-			tmpData = addrOrData
-		} else {
-			tmpData = C.Read(addrOrData, false)
-		}
-		compared := int(C.registers.Y) - int(tmpData)
-		C.registers.P.Carry = compared >= 0
-		C.registers.P.Negative = I2b(uint(compared & 0x80))
-		C.registers.P.Zero = !I2b(uint(compared & 0xff))
-		break
-	case "DEC":
-		data := (C.Read(addrOrData, false) - 1) & 0xFF
-		C.registers.P.Negative = I2b(data & 0x80)
-		C.registers.P.Zero = !I2b(data)
-		C.Write(addrOrData, byte(data))
-		break
-	case "DEX":
-		C.registers.X = (C.registers.X - 1) & 0xFF
-		C.registers.P.Negative = I2b(C.registers.X & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.X)
-		break
-	case "DEY":
-		C.registers.Y = (C.registers.Y - 1) & 0xFF
-		C.registers.P.Negative = I2b(C.registers.Y & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.Y)
-		break
-	case "EOR":
-		if mode == Immediate {
-			tmpData = addrOrData
-		} else {
-			tmpData = C.Read(addrOrData, false)
-		}
-		operated := tmpData ^ C.registers.A
-		C.registers.P.Negative = I2b(operated & 0x80)
-		C.registers.P.Zero = !I2b(operated)
-		C.registers.A = operated & 0xFF
-		break
-	case "INC":
-		data := (C.Read(addrOrData, false) + 1) & 0xFF
-		C.registers.P.Negative = I2b(data & 0x80)
-		C.registers.P.Zero = !I2b(data)
-		C.Write(addrOrData, byte(data))
-		break
-	case "INX":
-		C.registers.X = (C.registers.X + 1) & 0xFF
-		C.registers.P.Negative = I2b(C.registers.X & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.X)
-		break
-	case "INY":
-		C.registers.Y = (C.registers.Y + 1) & 0xFF
-		C.registers.P.Negative = I2b(C.registers.Y & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.Y)
-		break
-	case "LSR":
-		if mode == Accumulator {
-			acc := C.registers.A & 0xFF
-			C.registers.P.Carry = I2b(acc & 0x01)
-			C.registers.A = acc >> 1
-			C.registers.P.Zero = !I2b(C.registers.A)
-		} else {
-			data := C.Read(addrOrData, false)
-			C.registers.P.Carry = I2b(data & 0x01)
-			C.registers.P.Zero = !I2b(data >> 1)
-			C.Write(addrOrData, byte(data>>1))
-		}
-		C.registers.P.Negative = false
-		break
-	case "ORA":
-		if mode == Immediate {
-			tmpData = addrOrData
-		} else {
-			tmpData = C.Read(addrOrData, false)
-		}
-		operated := tmpData | C.registers.A
-		C.registers.P.Negative = I2b(operated & 0x80)
-		C.registers.P.Zero = !I2b(operated)
-		C.registers.A = operated & 0xFF
-		break
-	case "ROL":
-		if mode == Accumulator {
-			acc := C.registers.A
-			C.registers.A = (acc<<1)&0xFF | B2ix(C.registers.P.Carry, 0x01, 0x00)
-			C.registers.P.Carry = I2b(acc & 0x80)
-			C.registers.P.Zero = !I2b(C.registers.A)
-			C.registers.P.Negative = I2b(C.registers.A & 0x80)
-		} else {
-			data := C.Read(addrOrData, false)
-			writeData := (data<<1 | B2i(C.registers.P.Carry)) & 0xFF
-			C.Write(addrOrData, byte(writeData))
-			C.registers.P.Carry = !!I2b(data & 0x80)
-			C.registers.P.Zero = !I2b(writeData)
-			C.registers.P.Negative = I2b(writeData & 0x80)
-		}
-		break
-	case "ROR":
-		if mode == Accumulator {
-			acc := C.registers.A
-			C.registers.A = acc>>1 | B2ix(C.registers.P.Carry, 0x80, 0x00)
-			C.registers.P.Carry = I2b(acc & 0x01)
-			C.registers.P.Zero = !I2b(C.registers.A)
-			C.registers.P.Negative = I2b(C.registers.A & 0x80)
-		} else {
-			data := C.Read(addrOrData, false)
-			writeData := data>>1 | B2ix(C.registers.P.Carry, 0x80, 0x00)
-			C.Write(addrOrData, byte(writeData))
-			C.registers.P.Carry = I2b(data & 0x01)
-			C.registers.P.Zero = !I2b(writeData)
-			C.registers.P.Negative = I2b(writeData & 0x80)
-		}
-		break
-	case "SBC":
-		if mode == Immediate {
-			tmpData = addrOrData
-		} else {
-			tmpData = C.Read(addrOrData, false)
-		}
-		operated := int(C.registers.A) - int(tmpData) - int(B2ix(C.registers.P.Carry, 0, 1))
-		overflow := ((C.registers.A^uint(operated))&0x80) != 0 && ((C.registers.A^tmpData)&0x80) != 0
-		C.registers.P.Overflow = overflow
-		C.registers.P.Carry = operated >= 0
-		C.registers.P.Negative = Int2b(operated & 0x80)
-		C.registers.P.Zero = !I2b(uint(operated) & 0xFF)
-		C.registers.A = uint(operated) & 0xFF
-		break
-	case "PHA":
-		C.Push(byte(C.registers.A))
-		break
-	case "PHP":
-		C.registers.P.BreakMode = true
-		C.pushStatus()
-		break
-	case "PLA":
-		C.registers.A = C.Pop()
-		C.registers.P.Negative = I2b(C.registers.A & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.A)
-		break
-	case "PLP":
-		C.popStatus()
-		C.registers.P.Reserved = true
-		break
-	case "JMP":
-		C.registers.PC = addrOrData
-		break
-	case "JSR":
-		pc := C.registers.PC - 1
-		C.Push(byte((pc >> 8) & 0xFF))
-		C.Push(byte(pc & 0xFF))
-		C.registers.PC = addrOrData
-		break
-	case "RTS":
-		C.PopPC()
-		C.registers.PC++
-		break
-	case "RTI":
-		C.popStatus()
-		C.PopPC()
-		C.registers.P.Reserved = true
-		break
-	case "BCC":
-		if !C.registers.P.Carry {
-			C.Branch(addrOrData)
-		}
-		break
-	case "BCS":
-		if C.registers.P.Carry {
-			C.Branch(addrOrData)
-		}
-		break
-	case "BEQ":
-		if C.registers.P.Zero {
-			C.Branch(addrOrData)
-		}
-		break
-	case "BMI":
-		if C.registers.P.Negative {
-			C.Branch(addrOrData)
-		}
-		break
-	case "BNE":
-		if !C.registers.P.Zero {
-			C.Branch(addrOrData)
-		}
-		break
-	case "BPL":
-		if !C.registers.P.Negative {
-			C.Branch(addrOrData)
-		}
-		break
-	case "BVS":
-		if C.registers.P.Overflow {
-			C.Branch(addrOrData)
-		}
-		break
-	case "BVC":
-		if !C.registers.P.Overflow {
-			C.Branch(addrOrData)
-		}
-		break
-	case "CLD":
-		C.registers.P.DecimalMode = false
-		break
-	case "CLC":
-		C.registers.P.Carry = false
-		break
-	case "CLI":
-		C.registers.P.Interrupt = false
-		break
-	case "CLV":
-		C.registers.P.Overflow = false
-		break
-	case "SEC":
-		C.registers.P.Carry = true
-		break
-	case "SEI":
-		C.registers.P.Interrupt = true
-		break
-	case "SED":
-		C.registers.P.DecimalMode = true
-		break
-	case "BRK":
-		interrupt := C.registers.P.Interrupt
-		C.registers.PC++
-		C.Push(byte((C.registers.PC >> 8) & 0xFF))
-		C.Push(byte(C.registers.PC & 0xFF))
-		C.registers.P.BreakMode = true
-		C.pushStatus()
-		C.registers.P.Interrupt = true
-		// Ignore interrupt when already set.
-		if !interrupt {
-			C.registers.PC = C.Read(0xFFFE, true)
-		}
-		C.registers.PC--
-		break
-	case "NOP":
-		break
-	// Unofficial OpCode
-	case "NOPD":
-		C.registers.PC++
-		break
-	case "NOPI":
-		C.registers.PC += 2
-		break
-	case "LAX":
-		data := C.Read(addrOrData, false)
-		C.registers.A, C.registers.X = data, data
-		C.registers.P.Negative = I2b(C.registers.A & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.A)
-		break
-	case "SAX":
-		operated := C.registers.A & C.registers.X
-		C.Write(addrOrData, byte(operated))
-		break
-	case "DCP":
-		operated := (C.Read(addrOrData, false) - 1) & 0xFF
-		C.registers.P.Negative = I2b(((C.registers.A - operated) & 0x1FF) & 0x80)
-		C.registers.P.Zero = !I2b((C.registers.A - operated) & 0x1FF)
-		C.Write(addrOrData, byte(operated))
-		break
-	case "ISB":
-		data := (C.Read(addrOrData, false) + 1) & 0xFF
-		operated := (^data & 0xFF) + C.registers.A + B2i(C.registers.P.Carry)
-		overflow := !(((C.registers.A ^ data) & 0x80) != 0) && ((C.registers.A^operated)&0x80) != 0
-		C.registers.P.Overflow = overflow
-		C.registers.P.Carry = operated > 0xFF
-		C.registers.P.Negative = I2b(operated & 0x80)
-		C.registers.P.Zero = !I2b(operated & 0xFF)
-		C.registers.A = operated & 0xFF
-		C.Write(addrOrData, byte(data))
-		break
-	case "SLO":
-		data := C.Read(addrOrData, false)
-		C.registers.P.Carry = I2b(data & 0x80)
-		data = (data << 1) & 0xFF
-		C.registers.A |= data
-		C.registers.P.Negative = I2b(C.registers.A & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.A & 0xFF)
-		C.Write(addrOrData, byte(data))
-		break
-	case "RLA":
-		data := (C.Read(addrOrData, false) << 1) + B2i(C.registers.P.Carry)
-		C.registers.P.Carry = I2b(data & 0x100)
-		C.registers.A = (data & C.registers.A) & 0xFF
-		C.registers.P.Negative = I2b(C.registers.A & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.A & 0xFF)
-		C.Write(addrOrData, byte(data))
-		break
-	case "SRE":
-		data := C.Read(addrOrData, false)
-		C.registers.P.Carry = I2b(data & 0x01)
-		data >>= 1
-		C.registers.A ^= data
-		C.registers.P.Negative = I2b(C.registers.A & 0x80)
-		C.registers.P.Zero = !I2b(C.registers.A & 0xFF)
-		C.Write(addrOrData, byte(data))
-		break
-	case "RRA":
-		data := C.Read(addrOrData, false)
-		carry := data & 0x01
-		data = (data >> 1) | B2ix(C.registers.P.Carry, 0x80, 0x00)
-		operated := data + C.registers.A + carry
-		overflow := !(((C.registers.A ^ data) & 0x80) != 0) && ((C.registers.A^operated)&0x80) != 0
-		C.registers.P.Overflow = overflow
-		C.registers.P.Negative = I2b(operated & 0x80)
-		C.registers.P.Zero = !I2b(operated & 0xFF)
-		C.registers.A = operated & 0xFF
-		C.registers.P.Carry = operated > 0xFF
-		C.Write(addrOrData, byte(data))
-		break
-	default:
-		//panic(errors.New(fmt.Sprintf("Unknown  opcode 0x%02x %d (%s detected)\n", op, op, opInfo.BaseName)))
-		println(fmt.Sprintf("Unknown  opcode 0x%02x %d (%s detected)\n", op, op, opInfo.BaseName))
+// The high byte is fixed up one cycle after adding the index, meanwhile the unfixed address is read.
+func (C *Cpu) indexed(baseAddr uint, index uint, isWrite bool) uint {
+	addr := (baseAddr + index) & 0xFFFF
+	if isWrite || (addr&0xFF00) != (baseAddr&0xFF00) {
+		C.dummyRead((baseAddr & 0xFF00) | (addr & 0xFF))
 	}
+	return addr
 }
 
+// Run executes one instruction (or interrupt, or OAM DMA) and returns the CPU cycles it took.
+// The PPU and the APU are already caught up when it returns.
 func (C *Cpu) Run() uint {
-	if C.interrupts.IsNmiAssert() {
+	start := C.cycles
+	if C.jammed != nil {
+		// INFO: The halted CPU keeps the bus busy, the rest of the console still runs.
+		C.dummyRead(0xFFFF)
+		return 1
+	}
+	if C.dma != nil && C.dma.IsDmaProcessing() {
+		C.runDma()
+		return uint(C.cycles - start)
+	}
+	if C.previousNmi {
 		C.ProcessNmi()
+		return uint(C.cycles - start)
 	}
-	if C.interrupts.IsIrqAssert() {
+	if C.previousIrq {
 		C.processIrq()
+		return uint(C.cycles - start)
 	}
 
+	if C.tracer != nil {
+		C.tracer.Trace(C)
+	}
 	opcode := C.Fetch(C.registers.PC, false)
-	ocp := OpCodes[opcode]
-	data := C.getAddrOrDataWithAdditionalCycle(ocp.Addressing)
-	C.execOpCode(opcode, data)
-	return ocp.Cycle + data.additionalCycle + B2i(C.hasBranched)
-}
-
-func (C *Cpu) Dump() {
-	for {
-		pc := C.registers.PC
-		opcode := C.Fetch(C.registers.PC, false)
-		ocp := OpCodes[opcode]
-		data := C.getAddrOrDataWithAdditionalCycle(ocp.Addressing)
-		fmt.Printf("0x%04x\t%s\t%04x\n", pc, ocp.FullName, data.addrOrData)
+	instruction := &instructions[opcode]
+	addrOrData := C.getAddrOrData(instruction.addressing, instruction.isWrite)
+	instruction.handler(C, instruction.addressing, addrOrData)
+	return uint(C.cycles - start)
+}
+
+// OAM DMA halts the CPU for 513 cycles (+1 when it starts on an odd cycle),
+// then copies the page alternating read and write cycles.
+func (C *Cpu) runDma() {
+	C.tick()
+	if C.cycles%2 == 1 {
+		C.tick()
+	}
+	for i := uint(0); i < 0x100; i++ {
+		data := C.Read(C.dma.DmaAddr()+i, false)
+		C.tick()
+		C.dma.TransferDma(i, byte(data))
 	}
+	C.dma.FinishDma()
 }
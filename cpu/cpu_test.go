@@ -0,0 +1,94 @@
+package cpu_test
+
+import (
+	"testing"
+
+	"github.com/popsul/gones/console"
+	"github.com/popsul/gones/cpu"
+	"github.com/popsul/gones/interrupts"
+	"github.com/popsul/gones/reader"
+)
+
+/*
+	Endless loop over a page of RAM, a mix of the addressing modes and instructions games spend their time in.
+
+	8000  LDX #$00       8013  JSR $8020      8020  PHA
+	8002  LDA $0200,X    8016  INX            8021  LDA $10
+	8005  CLC            8017  BNE $8002      8023  LSR A
+	8006  ADC #$01       8019  JMP $8000      8024  STA $11
+	8008  STA $0200,X                         8026  PLA
+	800B  AND #$0F                            8027  RTS
+	800D  CMP #$08
+	800F  BCS $8013
+	8011  INC $10
+*/
+
+var benchmarkProgram = []byte{
+	0xA2, 0x00, 0xBD, 0x00, 0x02, 0x18, 0x69, 0x01, 0x9D, 0x00, 0x02, 0x29, 0x0F, 0xC9, 0x08, 0xB0,
+	0x02, 0xE6, 0x10, 0x20, 0x20, 0x80, 0xE8, 0xD0, 0xE9, 0x4C, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00,
+	0x48, 0xA5, 0x10, 0x4A, 0x85, 0x11, 0x68, 0x60,
+}
+
+func newBenchmarkCpu(program []byte) *cpu.Cpu {
+	rom := new(reader.NesRom)
+	rom.Program = make([]byte, 0x8000)
+	copy(rom.Program, program)
+	// Reset vector: 0x8000
+	rom.Program[0x7FFD] = 0x80
+	c, err := console.NewConsole(rom)
+	if err != nil {
+		panic(err)
+	}
+	return c.Cpu()
+}
+
+func BenchmarkRun(b *testing.B) {
+	c := newBenchmarkCpu(benchmarkProgram)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		c.Run()
+	}
+}
+
+// BenchmarkCpu runs the program on a flat memory, without the PPU and APU ticking in step.
+func BenchmarkCpu(b *testing.B) {
+	memory := cpu.NewMemory()
+	memory.Load(0x8000, benchmarkProgram)
+	// Reset vector: 0x8000
+	memory.Write(0xFFFD, 0x80)
+	c := cpu.NewCpu(memory, interrupts.NewInterrupts())
+	c.PowerOn()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		c.Run()
+	}
+}
+
+// Opcodes halting the CPU, they have no cycle count.
+var jamOpcodes = map[int]bool{
+	0x02: true, 0x12: true, 0x22: true, 0x32: true, 0x42: true, 0x52: true,
+	0x62: true, 0x72: true, 0x92: true, 0xB2: true, 0xD2: true, 0xF2: true,
+}
+
+// TestCycleTable compares the documented cycles with the bus accesses of each opcode,
+// without page crossing (operands and index registers are 0). Branches are covered by TestInstructions.
+func TestCycleTable(t *testing.T) {
+	for opcode := range cpu.Cycles {
+		if op, ok := cpu.OpCodes[uint(opcode)]; !ok || op.Cycle != cpu.Cycles[opcode] {
+			t.Errorf("%02X: OpCodes cycle %d, table %d", opcode, op.Cycle, cpu.Cycles[opcode])
+		}
+	}
+	for opcode := range cpu.Cycles {
+		if opcode&0x1F == 0x10 || jamOpcodes[opcode] {
+			continue
+		}
+		memory := cpu.NewMemory()
+		memory.Load(0x0200, []byte{byte(opcode), 0x00, 0x00})
+		c := cpu.NewCpu(memory, interrupts.NewInterrupts())
+		c.PowerOn()
+		c.SetPC(0x0200)
+		if cycles := c.Run(); cycles != cpu.Cycles[opcode] {
+			t.Errorf("%02X: %d cycles, table %d", opcode, cycles, cpu.Cycles[opcode])
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package cpu_test
+
+import "github.com/popsul/gones/reader"
+
+/*
+	Generated fixtures, TestNestest and TestFunctional use them when the original fixtures
+	are not in testdata.
+
+	| fixture                    | generated                                                |
+	+----------------------------+----------------------------------------------------------+
+	| nestest.nes, nestest.log   | nromTraceProgram in an NROM-128 image, testdata/         |
+	|                            | nrom_trace.log is its trace, checked by hand             |
+	| 6502_functional_test.bin   | functionalProgram at $0400                               |
+*/
+
+/*
+	Addressing modes with page crossings, flags, the stack, unofficial opcodes, writes to I/O
+	registers and the JMP indirect page wrap. A failed check stores $FF in $02 and loops.
+
+	C000  start:    LDX #$00
+	C002            STX $02
+	C004            STX $03
+	C006            LDA #$80
+	C008            STA $10
+	C00A            LDA #$02
+	C00C            STA $11
+	C00E            LDY #$90
+	C010            LDA #$5A
+	C012            STA ($10),Y
+	C014            LDA #$00
+	C016            LDA ($10),Y
+	C018            CMP #$5A
+	C01A            BNE fail
+	C01C            LDX $0280,Y
+	C01F            INX
+	C020            STX $0300
+	C023            LDX #$04
+	C025            LDA ($0C,X)
+	C027            BNE fail
+	C029            LDX #$20
+	C02B            LDA $02F0,X
+	C02E            CMP #$5A
+	C030            BNE fail
+	C032            LDX #$F5
+	C034            LDY $1B,X
+	C036            BPL fail
+	C038            CLC
+	C039            LDA #$50
+	C03B            ADC #$50
+	C03D            BVC fail
+	C03F            SEC
+	C040            SBC #$B0
+	C042            BCS fail
+	C044            CLV
+	C045            JSR sub
+	C048            *LAX $10
+	C04A            *SAX $12
+	C04C            *NOP $12
+	C04E            *DCP $12
+	C050            BCC fail
+	C052            LDA #$00
+	C054            STA $2001
+	C057            STA $4015
+	C05A            LDA #$6D
+	C05C            STA $02FF
+	C05F            LDA #$C0
+	C061            STA $0200
+	C064            JMP ($02FF)
+	C067  sub:      PHP
+	C068            PLA
+	C069            PHA
+	C06A            PLP
+	C06B            TSX
+	C06C            RTS
+	C06D  done:     LDA #$00
+	C06F            BEQ end
+	C071            NOP
+	C072  end:      JMP end
+	C075  fail:     LDA #$FF
+	C077            STA $02
+	C079  trap:     JMP trap
+*/
+
+var nromTraceProgram = []byte{
+	0xA2, 0x00, 0x86, 0x02, 0x86, 0x03, 0xA9, 0x80, 0x85, 0x10, 0xA9, 0x02, 0x85, 0x11, 0xA0, 0x90,
+	0xA9, 0x5A, 0x91, 0x10, 0xA9, 0x00, 0xB1, 0x10, 0xC9, 0x5A, 0xD0, 0x59, 0xBE, 0x80, 0x02, 0xE8,
+	0x8E, 0x00, 0x03, 0xA2, 0x04, 0xA1, 0x0C, 0xD0, 0x4C, 0xA2, 0x20, 0xBD, 0xF0, 0x02, 0xC9, 0x5A,
+	0xD0, 0x43, 0xA2, 0xF5, 0xB4, 0x1B, 0x10, 0x3D, 0x18, 0xA9, 0x50, 0x69, 0x50, 0x50, 0x36, 0x38,
+	0xE9, 0xB0, 0xB0, 0x31, 0xB8, 0x20, 0x67, 0xC0, 0xA7, 0x10, 0x87, 0x12, 0x04, 0x12, 0xC7, 0x12,
+	0x90, 0x23, 0xA9, 0x00, 0x8D, 0x01, 0x20, 0x8D, 0x15, 0x40, 0xA9, 0x6D, 0x8D, 0xFF, 0x02, 0xA9,
+	0xC0, 0x8D, 0x00, 0x02, 0x6C, 0xFF, 0x02, 0x08, 0x68, 0x48, 0x28, 0xBA, 0x60, 0xA9, 0x00, 0xF0,
+	0x01, 0xEA, 0x4C, 0x72, 0xC0, 0xA9, 0xFF, 0x85, 0x02, 0x4C, 0x79, 0xC0,
+}
+
+/*
+	Each case stores its number in $0200 and traps (branches to itself) when it fails,
+	$F0 and a trap at "success" mean every case passed.
+
+	| case | checks                                                        |
+	+------+---------------------------------------------------------------+
+	| 1    | ADC in a loop, 1 + 2 + ... + 10                               |
+	| 2    | shift and add multiplication, 13 * 11                         |
+	| 3    | 16 pushes and pulls, the stack pointer is restored            |
+	| 4    | copy with (indirect),Y                                        |
+	| 5    | BIT flags seen by PHP                                         |
+	| 6    | ADC ignores the decimal flag                                  |
+	| 7    | BRK sets B on the stack and returns after its padding byte    |
+
+	0400            LDA #$01
+	0402            STA $0200
+	0405            LDA #$00
+	0407            LDX #$0A
+	0409  sum:      STX $00
+	040B            CLC
+	040C            ADC $00
+	040E            DEX
+	040F            BNE sum
+	0411            CMP #$37
+	0413            BNE *
+	0415            LDA #$02
+	0417            STA $0200
+	041A            LDA #$0D
+	041C            STA $01
+	041E            LDA #$0B
+	0420            STA $02
+	0422            LDA #$00
+	0424            LDX #$08
+	0426  mul:      ASL A
+	0427            ASL $02
+	0429            BCC mul_next
+	042B            CLC
+	042C            ADC $01
+	042E  mul_next: DEX
+	042F            BNE mul
+	0431            CMP #$8F
+	0433            BNE *
+	0435            LDA #$03
+	0437            STA $0200
+	043A            LDX #$00
+	043C  push:     TXA
+	043D            PHA
+	043E            INX
+	043F            CPX #$10
+	0441            BNE push
+	0443            LDX #$0F
+	0445  pull:     PLA
+	0446            STX $03
+	0448            CMP $03
+	044A            BNE *
+	044C            DEX
+	044D            BPL pull
+	044F            TSX
+	0450            CPX #$FD
+	0452            BNE *
+	0454            LDA #$04
+	0456            STA $0200
+	0459            LDA #$00
+	045B            STA $20
+	045D            LDA #$03
+	045F            STA $21
+	0461            LDA #$80
+	0463            STA $22
+	0465            LDA #$03
+	0467            STA $23
+	0469            LDY #$00
+	046B  fill:     TYA
+	046C            EOR #$A5
+	046E            STA ($20),Y
+	0470            INY
+	0471            CPY #$10
+	0473            BNE fill
+	0475            LDY #$0F
+	0477  copy:     LDA ($20),Y
+	0479            STA ($22),Y
+	047B            DEY
+	047C            BPL copy
+	047E            LDX #$0F
+	0480  verify:   LDA $0300,X
+	0483            CMP $0380,X
+	0486            BNE *
+	0488            DEX
+	0489            BPL verify
+	048B            LDA #$05
+	048D            STA $0200
+	0490            LDA #$C0
+	0492            STA $04
+	0494            CLC
+	0495            LDA #$00
+	0497            BIT $04
+	0499            PHP
+	049A            PLA
+	049B            AND #$C3
+	049D            CMP #$C2
+	049F            BNE *
+	04A1            LDA #$06
+	04A3            STA $0200
+	04A6            SED
+	04A7            CLC
+	04A8            LDA #$09
+	04AA            ADC #$01
+	04AC            CLD
+	04AD            CMP #$0A
+	04AF            BNE *
+	04B1            LDA #$07
+	04B3            STA $0200
+	04B6            LDA #$D4
+	04B8            STA $FFFE
+	04BB            LDA #$04
+	04BD            STA $FFFF
+	04C0            LDA #$00
+	04C2            STA $05
+	04C4            BRK
+	04C5            .byte $EA
+	04C6            LDA $05
+	04C8            CMP #$01
+	04CA            BNE *
+	04CC            LDA #$F0
+	04CE            STA $0200
+	04D1  success:  JMP success
+	04D4  irq:      INC $05
+	04D6            PLA
+	04D7            PHA
+	04D8            AND #$10
+	04DA  irq_trap: BEQ *
+	04DC            RTI
+*/
+
+var functionalProgram = []byte{
+	0xA9, 0x01, 0x8D, 0x00, 0x02, 0xA9, 0x00, 0xA2, 0x0A, 0x86, 0x00, 0x18, 0x65, 0x00, 0xCA, 0xD0,
+	0xF8, 0xC9, 0x37, 0xD0, 0xFE, 0xA9, 0x02, 0x8D, 0x00, 0x02, 0xA9, 0x0D, 0x85, 0x01, 0xA9, 0x0B,
+	0x85, 0x02, 0xA9, 0x00, 0xA2, 0x08, 0x0A, 0x06, 0x02, 0x90, 0x03, 0x18, 0x65, 0x01, 0xCA, 0xD0,
+	0xF5, 0xC9, 0x8F, 0xD0, 0xFE, 0xA9, 0x03, 0x8D, 0x00, 0x02, 0xA2, 0x00, 0x8A, 0x48, 0xE8, 0xE0,
+	0x10, 0xD0, 0xF9, 0xA2, 0x0F, 0x68, 0x86, 0x03, 0xC5, 0x03, 0xD0, 0xFE, 0xCA, 0x10, 0xF6, 0xBA,
+	0xE0, 0xFD, 0xD0, 0xFE, 0xA9, 0x04, 0x8D, 0x00, 0x02, 0xA9, 0x00, 0x85, 0x20, 0xA9, 0x03, 0x85,
+	0x21, 0xA9, 0x80, 0x85, 0x22, 0xA9, 0x03, 0x85, 0x23, 0xA0, 0x00, 0x98, 0x49, 0xA5, 0x91, 0x20,
+	0xC8, 0xC0, 0x10, 0xD0, 0xF6, 0xA0, 0x0F, 0xB1, 0x20, 0x91, 0x22, 0x88, 0x10, 0xF9, 0xA2, 0x0F,
+	0xBD, 0x00, 0x03, 0xDD, 0x80, 0x03, 0xD0, 0xFE, 0xCA, 0x10, 0xF5, 0xA9, 0x05, 0x8D, 0x00, 0x02,
+	0xA9, 0xC0, 0x85, 0x04, 0x18, 0xA9, 0x00, 0x24, 0x04, 0x08, 0x68, 0x29, 0xC3, 0xC9, 0xC2, 0xD0,
+	0xFE, 0xA9, 0x06, 0x8D, 0x00, 0x02, 0xF8, 0x18, 0xA9, 0x09, 0x69, 0x01, 0xD8, 0xC9, 0x0A, 0xD0,
+	0xFE, 0xA9, 0x07, 0x8D, 0x00, 0x02, 0xA9, 0xD4, 0x8D, 0xFE, 0xFF, 0xA9, 0x04, 0x8D, 0xFF, 0xFF,
+	0xA9, 0x00, 0x85, 0x05, 0x00, 0xEA, 0xA5, 0x05, 0xC9, 0x01, 0xD0, 0xFE, 0xA9, 0xF0, 0x8D, 0x00,
+	0x02, 0x4C, 0xD1, 0x04, 0xE6, 0x05, 0x68, 0x48, 0x29, 0x10, 0xF0, 0xFE, 0x40,
+}
+
+// newNromImage returns an NROM-128 iNES image with the program at $C000, the reset vector points to it.
+func newNromImage(program []byte) []byte {
+	image := make([]byte, reader.NES_HEADER_SIZE+reader.PROGRAM_ROM_SIZE+reader.CHARACTER_ROM_SIZE)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, 1, 1})
+	copy(image[reader.NES_HEADER_SIZE:], program)
+	// Reset vector: 0xC000
+	image[reader.NES_HEADER_SIZE+reader.PROGRAM_ROM_SIZE-3] = 0xC0
+	return image
+}
+
+// newFunctionalImage returns the 64K RAM image with the program at FUNCTIONAL_START.
+func newFunctionalImage(program []byte) []byte {
+	image := make([]byte, FUNCTIONAL_START+len(program))
+	copy(image[FUNCTIONAL_START:], program)
+	return image
+}
@@ -0,0 +1,616 @@
+package cpu
+
+import (
+	. "github.com/popsul/gones/common"
+)
+
+// Instruction handlers, addrOrData is the operand for immediate addressing and the effective address otherwise.
+
+func (C *Cpu) lda(mode Addressing, addrOrData uint) {
+	var tmpData uint
+	if mode == Immediate {
+		tmpData = addrOrData
+	} else {
+		tmpData = C.Read(addrOrData, false)
+	}
+	C.registers.A = tmpData
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A)
+}
+
+func (C *Cpu) ldx(mode Addressing, addrOrData uint) {
+	var tmpData uint
+	if mode == Immediate {
+		tmpData = addrOrData
+	} else {
+		tmpData = C.Read(addrOrData, false)
+	}
+	C.registers.X = tmpData
+	C.registers.P.Negative = I2b(C.registers.X & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.X)
+}
+
+func (C *Cpu) ldy(mode Addressing, addrOrData uint) {
+	var tmpData uint
+	if mode == Immediate {
+		tmpData = addrOrData
+	} else {
+		tmpData = C.Read(addrOrData, false)
+	}
+	C.registers.Y = tmpData
+	C.registers.P.Negative = I2b(C.registers.Y & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.Y)
+}
+
+func (C *Cpu) sta(mode Addressing, addrOrData uint) {
+	//fmt.Printf("STA 0x%04x 0x%02x\n", addrOrData, C.registers.A)
+	C.Write(addrOrData, byte(C.registers.A))
+}
+
+func (C *Cpu) stx(mode Addressing, addrOrData uint) {
+	C.Write(addrOrData, byte(C.registers.X))
+}
+
+func (C *Cpu) sty(mode Addressing, addrOrData uint) {
+	C.Write(addrOrData, byte(C.registers.Y))
+}
+
+func (C *Cpu) tax(mode Addressing, addrOrData uint) {
+	C.registers.X = C.registers.A
+	C.registers.P.Negative = I2b(C.registers.X & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.X)
+}
+
+func (C *Cpu) tay(mode Addressing, addrOrData uint) {
+	C.registers.Y = C.registers.A
+	C.registers.P.Negative = I2b(C.registers.Y & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.Y)
+}
+
+func (C *Cpu) tsx(mode Addressing, addrOrData uint) {
+	C.registers.X = C.registers.SP & 0xFF
+	C.registers.P.Negative = I2b(C.registers.X & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.X)
+}
+
+func (C *Cpu) txa(mode Addressing, addrOrData uint) {
+	C.registers.A = C.registers.X
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A)
+}
+
+func (C *Cpu) txs(mode Addressing, addrOrData uint) {
+	C.registers.SP = C.registers.X + 0x0100
+}
+
+func (C *Cpu) tya(mode Addressing, addrOrData uint) {
+	C.registers.A = C.registers.Y
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A)
+}
+
+func (C *Cpu) adc(mode Addressing, addrOrData uint) {
+	var tmpData uint
+	if mode == Immediate {
+		tmpData = addrOrData
+	} else {
+		tmpData = C.Read(addrOrData, false)
+	}
+	operated := tmpData + C.registers.A + B2i(C.registers.P.Carry)
+	overflow := !(((C.registers.A ^ tmpData) & 0x80) != 0) && ((C.registers.A^operated)&0x80) != 0
+	C.registers.P.Overflow = overflow
+	C.registers.P.Carry = operated > 0xFF
+	C.registers.P.Negative = I2b(operated & 0x80)
+	C.registers.P.Zero = !I2b(operated & 0xFF)
+	C.registers.A = operated & 0xFF
+}
+
+func (C *Cpu) and(mode Addressing, addrOrData uint) {
+	var tmpData uint
+	if mode == Immediate {
+		tmpData = addrOrData
+	} else {
+		tmpData = C.Read(addrOrData, false)
+	}
+	operated := tmpData & C.registers.A
+	C.registers.P.Negative = I2b(operated & 0x80)
+	C.registers.P.Zero = !I2b(operated)
+	C.registers.A = operated & 0xFF
+}
+
+func (C *Cpu) asl(mode Addressing, addrOrData uint) {
+	if mode == Accumulator {
+		acc := C.registers.A
+		C.registers.P.Carry = !!I2b(acc & 0x80)
+		C.registers.A = (acc << 1) & 0xFF
+		C.registers.P.Zero = !I2b(C.registers.A)
+		C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	} else {
+		data := C.readModify(addrOrData)
+		C.registers.P.Carry = I2b(data & 0x80)
+		shifted := (data << 1) & 0xFF
+		C.Write(addrOrData, byte(shifted))
+		C.registers.P.Zero = !I2b(shifted)
+		C.registers.P.Negative = I2b(shifted & 0x80)
+	}
+}
+
+func (C *Cpu) bit(mode Addressing, addrOrData uint) {
+	data := C.Read(addrOrData, false)
+	C.registers.P.Negative = I2b(data & 0x80)
+	C.registers.P.Overflow = I2b(data & 0x40)
+	C.registers.P.Zero = !I2b(C.registers.A & data)
+}
+
+func (C *Cpu) cmp(mode Addressing, addrOrData uint) {
+	var tmpData uint
+	if mode == Immediate {
+		tmpData = addrOrData
+	} else {
+		tmpData = C.Read(addrOrData, false)
+	}
+	compared := int(C.registers.A) - int(tmpData)
+	C.registers.P.Carry = compared >= 0
+	C.registers.P.Negative = I2b(uint(compared & 0x80))
+	C.registers.P.Zero = !I2b(uint(compared & 0xff))
+}
+
+func (C *Cpu) cpx(mode Addressing, addrOrData uint) {
+	var tmpData uint
+	if mode == Immediate {
+		tmpData = addrOrData
+	} else {
+		tmpData = C.Read(addrOrData, false)
+	}
+	compared := int(C.registers.X) - int(tmpData)
+	C.registers.P.Carry = compared >= 0
+	C.registers.P.Negative = I2b(uint(compared) & 0x80)
+	C.registers.P.Zero = !I2b(uint(compared) & 0xff)
+}
+
+func (C *Cpu) cpy(mode Addressing, addrOrData uint) {
+	var tmpData uint
+	if mode == Immediate {
+		tmpData = addrOrData
+	} else {
+		tmpData = C.Read(addrOrData, false)
+	}
+	compared := int(C.registers.Y) - int(tmpData)
+	C.registers.P.Carry = compared >= 0
+	C.registers.P.Negative = I2b(uint(compared & 0x80))
+	C.registers.P.Zero = !I2b(uint(compared & 0xff))
+}
+
+func (C *Cpu) dec(mode Addressing, addrOrData uint) {
+	data := (C.readModify(addrOrData) - 1) & 0xFF
+	C.registers.P.Negative = I2b(data & 0x80)
+	C.registers.P.Zero = !I2b(data)
+	C.Write(addrOrData, byte(data))
+}
+
+func (C *Cpu) dex(mode Addressing, addrOrData uint) {
+	C.registers.X = (C.registers.X - 1) & 0xFF
+	C.registers.P.Negative = I2b(C.registers.X & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.X)
+}
+
+func (C *Cpu) dey(mode Addressing, addrOrData uint) {
+	C.registers.Y = (C.registers.Y - 1) & 0xFF
+	C.registers.P.Negative = I2b(C.registers.Y & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.Y)
+}
+
+func (C *Cpu) eor(mode Addressing, addrOrData uint) {
+	var tmpData uint
+	if mode == Immediate {
+		tmpData = addrOrData
+	} else {
+		tmpData = C.Read(addrOrData, false)
+	}
+	operated := tmpData ^ C.registers.A
+	C.registers.P.Negative = I2b(operated & 0x80)
+	C.registers.P.Zero = !I2b(operated)
+	C.registers.A = operated & 0xFF
+}
+
+func (C *Cpu) inc(mode Addressing, addrOrData uint) {
+	data := (C.readModify(addrOrData) + 1) & 0xFF
+	C.registers.P.Negative = I2b(data & 0x80)
+	C.registers.P.Zero = !I2b(data)
+	C.Write(addrOrData, byte(data))
+}
+
+func (C *Cpu) inx(mode Addressing, addrOrData uint) {
+	C.registers.X = (C.registers.X + 1) & 0xFF
+	C.registers.P.Negative = I2b(C.registers.X & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.X)
+}
+
+func (C *Cpu) iny(mode Addressing, addrOrData uint) {
+	C.registers.Y = (C.registers.Y + 1) & 0xFF
+	C.registers.P.Negative = I2b(C.registers.Y & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.Y)
+}
+
+func (C *Cpu) lsr(mode Addressing, addrOrData uint) {
+	if mode == Accumulator {
+		acc := C.registers.A & 0xFF
+		C.registers.P.Carry = I2b(acc & 0x01)
+		C.registers.A = acc >> 1
+		C.registers.P.Zero = !I2b(C.registers.A)
+	} else {
+		data := C.readModify(addrOrData)
+		C.registers.P.Carry = I2b(data & 0x01)
+		C.registers.P.Zero = !I2b(data >> 1)
+		C.Write(addrOrData, byte(data>>1))
+	}
+	C.registers.P.Negative = false
+}
+
+func (C *Cpu) ora(mode Addressing, addrOrData uint) {
+	var tmpData uint
+	if mode == Immediate {
+		tmpData = addrOrData
+	} else {
+		tmpData = C.Read(addrOrData, false)
+	}
+	operated := tmpData | C.registers.A
+	C.registers.P.Negative = I2b(operated & 0x80)
+	C.registers.P.Zero = !I2b(operated)
+	C.registers.A = operated & 0xFF
+}
+
+func (C *Cpu) rol(mode Addressing, addrOrData uint) {
+	if mode == Accumulator {
+		acc := C.registers.A
+		C.registers.A = (acc<<1)&0xFF | B2ix(C.registers.P.Carry, 0x01, 0x00)
+		C.registers.P.Carry = I2b(acc & 0x80)
+		C.registers.P.Zero = !I2b(C.registers.A)
+		C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	} else {
+		data := C.readModify(addrOrData)
+		writeData := (data<<1 | B2i(C.registers.P.Carry)) & 0xFF
+		C.Write(addrOrData, byte(writeData))
+		C.registers.P.Carry = !!I2b(data & 0x80)
+		C.registers.P.Zero = !I2b(writeData)
+		C.registers.P.Negative = I2b(writeData & 0x80)
+	}
+}
+
+func (C *Cpu) ror(mode Addressing, addrOrData uint) {
+	if mode == Accumulator {
+		acc := C.registers.A
+		C.registers.A = acc>>1 | B2ix(C.registers.P.Carry, 0x80, 0x00)
+		C.registers.P.Carry = I2b(acc & 0x01)
+		C.registers.P.Zero = !I2b(C.registers.A)
+		C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	} else {
+		data := C.readModify(addrOrData)
+		writeData := data>>1 | B2ix(C.registers.P.Carry, 0x80, 0x00)
+		C.Write(addrOrData, byte(writeData))
+		C.registers.P.Carry = I2b(data & 0x01)
+		C.registers.P.Zero = !I2b(writeData)
+		C.registers.P.Negative = I2b(writeData & 0x80)
+	}
+}
+
+func (C *Cpu) sbc(mode Addressing, addrOrData uint) {
+	var tmpData uint
+	if mode == Immediate {
+		tmpData = addrOrData
+	} else {
+		tmpData = C.Read(addrOrData, false)
+	}
+	operated := int(C.registers.A) - int(tmpData) - int(B2ix(C.registers.P.Carry, 0, 1))
+	overflow := ((C.registers.A^uint(operated))&0x80) != 0 && ((C.registers.A^tmpData)&0x80) != 0
+	C.registers.P.Overflow = overflow
+	C.registers.P.Carry = operated >= 0
+	C.registers.P.Negative = Int2b(operated & 0x80)
+	C.registers.P.Zero = !I2b(uint(operated) & 0xFF)
+	C.registers.A = uint(operated) & 0xFF
+}
+
+func (C *Cpu) pha(mode Addressing, addrOrData uint) {
+	C.Push(byte(C.registers.A))
+}
+
+func (C *Cpu) php(mode Addressing, addrOrData uint) {
+	C.registers.P.BreakMode = true
+	C.pushStatus()
+}
+
+func (C *Cpu) pla(mode Addressing, addrOrData uint) {
+	C.dummyRead(C.stackAddr())
+	C.registers.A = C.Pop()
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A)
+}
+
+func (C *Cpu) plp(mode Addressing, addrOrData uint) {
+	C.dummyRead(C.stackAddr())
+	C.popStatus()
+	C.registers.P.Reserved = true
+}
+
+func (C *Cpu) jmp(mode Addressing, addrOrData uint) {
+	C.registers.PC = addrOrData
+}
+
+func (C *Cpu) jsr(mode Addressing, addrOrData uint) {
+	pc := C.registers.PC - 1
+	C.dummyRead(C.stackAddr())
+	C.Push(byte((pc >> 8) & 0xFF))
+	C.Push(byte(pc & 0xFF))
+	C.registers.PC = addrOrData
+}
+
+func (C *Cpu) rts(mode Addressing, addrOrData uint) {
+	C.dummyRead(C.stackAddr())
+	C.PopPC()
+	C.dummyRead(C.registers.PC)
+	C.registers.PC++
+}
+
+func (C *Cpu) rti(mode Addressing, addrOrData uint) {
+	C.dummyRead(C.stackAddr())
+	C.popStatus()
+	C.PopPC()
+	C.registers.P.Reserved = true
+}
+
+func (C *Cpu) bcc(mode Addressing, addrOrData uint) {
+	if !C.registers.P.Carry {
+		C.Branch(addrOrData)
+	}
+}
+
+func (C *Cpu) bcs(mode Addressing, addrOrData uint) {
+	if C.registers.P.Carry {
+		C.Branch(addrOrData)
+	}
+}
+
+func (C *Cpu) beq(mode Addressing, addrOrData uint) {
+	if C.registers.P.Zero {
+		C.Branch(addrOrData)
+	}
+}
+
+func (C *Cpu) bmi(mode Addressing, addrOrData uint) {
+	if C.registers.P.Negative {
+		C.Branch(addrOrData)
+	}
+}
+
+func (C *Cpu) bne(mode Addressing, addrOrData uint) {
+	if !C.registers.P.Zero {
+		C.Branch(addrOrData)
+	}
+}
+
+func (C *Cpu) bpl(mode Addressing, addrOrData uint) {
+	if !C.registers.P.Negative {
+		C.Branch(addrOrData)
+	}
+}
+
+func (C *Cpu) bvs(mode Addressing, addrOrData uint) {
+	if C.registers.P.Overflow {
+		C.Branch(addrOrData)
+	}
+}
+
+func (C *Cpu) bvc(mode Addressing, addrOrData uint) {
+	if !C.registers.P.Overflow {
+		C.Branch(addrOrData)
+	}
+}
+
+func (C *Cpu) cld(mode Addressing, addrOrData uint) {
+	C.registers.P.DecimalMode = false
+}
+
+func (C *Cpu) clc(mode Addressing, addrOrData uint) {
+	C.registers.P.Carry = false
+}
+
+func (C *Cpu) cli(mode Addressing, addrOrData uint) {
+	C.registers.P.Interrupt = false
+}
+
+func (C *Cpu) clv(mode Addressing, addrOrData uint) {
+	C.registers.P.Overflow = false
+}
+
+func (C *Cpu) sec(mode Addressing, addrOrData uint) {
+	C.registers.P.Carry = true
+}
+
+func (C *Cpu) sei(mode Addressing, addrOrData uint) {
+	C.registers.P.Interrupt = true
+}
+
+func (C *Cpu) sed(mode Addressing, addrOrData uint) {
+	C.registers.P.DecimalMode = true
+}
+
+func (C *Cpu) brk(mode Addressing, addrOrData uint) {
+	// INFO: The byte after BRK is skipped, it was read by the implied addressing cycle.
+	C.registers.PC++
+	C.interrupt(0xFFFE, true)
+}
+
+func (C *Cpu) nop(mode Addressing, addrOrData uint) {
+	// Unofficial NOPs with an operand read it.
+	if mode != Implied && mode != Immediate {
+		C.dummyRead(addrOrData)
+	}
+}
+
+func (C *Cpu) lax(mode Addressing, addrOrData uint) {
+	data := C.Read(addrOrData, false)
+	C.registers.A, C.registers.X = data, data
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A)
+}
+
+func (C *Cpu) sax(mode Addressing, addrOrData uint) {
+	operated := C.registers.A & C.registers.X
+	C.Write(addrOrData, byte(operated))
+}
+
+func (C *Cpu) dcp(mode Addressing, addrOrData uint) {
+	operated := (C.readModify(addrOrData) - 1) & 0xFF
+	C.registers.P.Carry = C.registers.A >= operated
+	C.registers.P.Negative = I2b(((C.registers.A - operated) & 0x1FF) & 0x80)
+	C.registers.P.Zero = !I2b((C.registers.A - operated) & 0x1FF)
+	C.Write(addrOrData, byte(operated))
+}
+
+func (C *Cpu) isb(mode Addressing, addrOrData uint) {
+	data := (C.readModify(addrOrData) + 1) & 0xFF
+	operated := (^data & 0xFF) + C.registers.A + B2i(C.registers.P.Carry)
+	overflow := ((C.registers.A^data)&0x80) != 0 && ((C.registers.A^operated)&0x80) != 0
+	C.registers.P.Overflow = overflow
+	C.registers.P.Carry = operated > 0xFF
+	C.registers.P.Negative = I2b(operated & 0x80)
+	C.registers.P.Zero = !I2b(operated & 0xFF)
+	C.registers.A = operated & 0xFF
+	C.Write(addrOrData, byte(data))
+}
+
+func (C *Cpu) slo(mode Addressing, addrOrData uint) {
+	data := C.readModify(addrOrData)
+	C.registers.P.Carry = I2b(data & 0x80)
+	data = (data << 1) & 0xFF
+	C.registers.A |= data
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A & 0xFF)
+	C.Write(addrOrData, byte(data))
+}
+
+func (C *Cpu) rla(mode Addressing, addrOrData uint) {
+	data := (C.readModify(addrOrData) << 1) + B2i(C.registers.P.Carry)
+	C.registers.P.Carry = I2b(data & 0x100)
+	C.registers.A = (data & C.registers.A) & 0xFF
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A & 0xFF)
+	C.Write(addrOrData, byte(data))
+}
+
+func (C *Cpu) sre(mode Addressing, addrOrData uint) {
+	data := C.readModify(addrOrData)
+	C.registers.P.Carry = I2b(data & 0x01)
+	data >>= 1
+	C.registers.A ^= data
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A & 0xFF)
+	C.Write(addrOrData, byte(data))
+}
+
+func (C *Cpu) rra(mode Addressing, addrOrData uint) {
+	data := C.readModify(addrOrData)
+	carry := data & 0x01
+	data = (data >> 1) | B2ix(C.registers.P.Carry, 0x80, 0x00)
+	operated := data + C.registers.A + carry
+	overflow := !(((C.registers.A ^ data) & 0x80) != 0) && ((C.registers.A^operated)&0x80) != 0
+	C.registers.P.Overflow = overflow
+	C.registers.P.Negative = I2b(operated & 0x80)
+	C.registers.P.Zero = !I2b(operated & 0xFF)
+	C.registers.A = operated & 0xFF
+	C.registers.P.Carry = operated > 0xFF
+	C.Write(addrOrData, byte(data))
+}
+
+func (C *Cpu) anc(mode Addressing, addrOrData uint) {
+	C.registers.A &= addrOrData
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A)
+	C.registers.P.Carry = C.registers.P.Negative
+}
+
+func (C *Cpu) alr(mode Addressing, addrOrData uint) {
+	acc := C.registers.A & addrOrData
+	C.registers.P.Carry = I2b(acc & 0x01)
+	C.registers.A = acc >> 1
+	C.registers.P.Negative = false
+	C.registers.P.Zero = !I2b(C.registers.A)
+}
+
+// AND, then ROR with the flags of the adder: C is bit 6, V is bit 6 xor bit 5.
+func (C *Cpu) arr(mode Addressing, addrOrData uint) {
+	acc := C.registers.A & addrOrData
+	C.registers.A = acc>>1 | B2ix(C.registers.P.Carry, 0x80, 0x00)
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A)
+	C.registers.P.Carry = I2b(C.registers.A & 0x40)
+	C.registers.P.Overflow = I2b((C.registers.A>>6 ^ C.registers.A>>5) & 0x01)
+}
+
+func (C *Cpu) axs(mode Addressing, addrOrData uint) {
+	data := C.registers.A & C.registers.X
+	C.registers.P.Carry = data >= addrOrData
+	C.registers.X = (data - addrOrData) & 0xFF
+	C.registers.P.Negative = I2b(C.registers.X & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.X)
+}
+
+// NOTE: The magic constant differs between chips, 0xEE is the most common one.
+const UNSTABLE_MAGIC = 0xEE
+
+func (C *Cpu) xaa(mode Addressing, addrOrData uint) {
+	C.registers.A = (C.registers.A | UNSTABLE_MAGIC) & C.registers.X & addrOrData
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A)
+}
+
+func (C *Cpu) lxa(mode Addressing, addrOrData uint) {
+	data := (C.registers.A | UNSTABLE_MAGIC) & addrOrData
+	C.registers.A, C.registers.X = data, data
+	C.registers.P.Negative = I2b(C.registers.A & 0x80)
+	C.registers.P.Zero = !I2b(C.registers.A)
+}
+
+func (C *Cpu) las(mode Addressing, addrOrData uint) {
+	data := C.Read(addrOrData, false) & C.registers.SP & 0xFF
+	C.registers.A, C.registers.X = data, data
+	C.registers.SP = data | 0x0100
+	C.registers.P.Negative = I2b(data & 0x80)
+	C.registers.P.Zero = !I2b(data)
+}
+
+func (C *Cpu) shy(mode Addressing, addrOrData uint) {
+	C.storeHigh(addrOrData, C.registers.X, C.registers.Y)
+}
+
+func (C *Cpu) shx(mode Addressing, addrOrData uint) {
+	C.storeHigh(addrOrData, C.registers.Y, C.registers.X)
+}
+
+func (C *Cpu) tas(mode Addressing, addrOrData uint) {
+	C.registers.SP = (C.registers.A & C.registers.X) | 0x0100
+	C.storeHigh(addrOrData, C.registers.Y, C.registers.A&C.registers.X)
+}
+
+func (C *Cpu) ahx(mode Addressing, addrOrData uint) {
+	C.storeHigh(addrOrData, C.registers.Y, C.registers.A&C.registers.X)
+}
+
+// Stores data AND (high byte of the unindexed address + 1). When the index crosses a page,
+// the stored value also replaces the high byte of the address.
+func (C *Cpu) storeHigh(addr uint, index uint, data uint) {
+	baseAddr := (addr - index) & 0xFFFF
+	data &= ((baseAddr >> 8) + 1) & 0xFF
+	if (baseAddr & 0xFF00) != (addr & 0xFF00) {
+		addr = data<<8 | addr&0xFF
+	}
+	C.Write(addr, byte(data))
+}
+
+func (C *Cpu) jam(mode Addressing, addrOrData uint) {
+	C.registers.PC--
+	registers := *C.registers
+	status := *registers.P
+	registers.P = &status
+	C.jammed = &JamError{C.Read(C.registers.PC, false), registers}
+}
@@ -9,23 +9,32 @@ type OpCode struct {
 
 var Cycles = [...]uint{
 	7, 6, 2, 8, 3, 3, 5, 5, 3, 2, 2, 2, 4, 4, 6, 6,
-	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 6, 7,
+	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
 	6, 6, 2, 8, 3, 3, 5, 5, 4, 2, 2, 2, 4, 4, 6, 6,
-	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 6, 7,
+	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
 	6, 6, 2, 8, 3, 3, 5, 5, 3, 2, 2, 2, 3, 4, 6, 6,
-	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 6, 7,
+	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
 	6, 6, 2, 8, 3, 3, 5, 5, 4, 2, 2, 2, 5, 4, 6, 6,
-	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 6, 7,
+	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
 	2, 6, 2, 6, 3, 3, 3, 3, 2, 2, 2, 2, 4, 4, 4, 4,
-	2, 6, 2, 6, 4, 4, 4, 4, 2, 4, 2, 5, 5, 4, 5, 5,
+	2, 6, 2, 6, 4, 4, 4, 4, 2, 5, 2, 5, 5, 5, 5, 5,
 	2, 6, 2, 6, 3, 3, 3, 3, 2, 2, 2, 2, 4, 4, 4, 4,
 	2, 5, 2, 5, 4, 4, 4, 4, 2, 4, 2, 4, 4, 4, 4, 4,
 	2, 6, 2, 8, 3, 3, 5, 5, 2, 2, 2, 2, 4, 4, 6, 6,
 	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
-	2, 6, 3, 8, 3, 3, 5, 5, 2, 2, 2, 2, 4, 4, 6, 6,
+	2, 6, 2, 8, 3, 3, 5, 5, 2, 2, 2, 2, 4, 4, 6, 6,
 	2, 5, 2, 8, 4, 4, 6, 6, 2, 4, 2, 7, 4, 4, 7, 7,
 }
 
+// Indexed addressing of these instructions always spends the high byte fix-up cycle,
+// others only when the page is crossed.
+var WriteInstructions = map[string]bool{
+	"STA": true, "STX": true, "STY": true, "SAX": true,
+	"ASL": true, "LSR": true, "ROL": true, "ROR": true, "INC": true, "DEC": true,
+	"SLO": true, "RLA": true, "SRE": true, "RRA": true, "DCP": true, "ISB": true,
+	"SHY": true, "SHX": true, "TAS": true, "AHX": true,
+}
+
 var OpCodes = map[uint]OpCode{
 	0xA9: {"LDA_IMM", "LDA", Immediate, Cycles[0xA9]},
 	0xA5: {"LDA_ZERO", "LDA", ZeroPage, Cycles[0xA5]},
@@ -186,55 +195,64 @@ var OpCodes = map[uint]OpCode{
 	0x7A: {"NOP", "NOP", Implied, Cycles[0x7A]},
 	0xDA: {"NOP", "NOP", Implied, Cycles[0xDA]},
 	0xFA: {"NOP", "NOP", Implied, Cycles[0xFA]},
-	0x02: {"NOP", "NOP", Implied, Cycles[0x02]},
-	0x12: {"NOP", "NOP", Implied, Cycles[0x12]},
-	0x22: {"NOP", "NOP", Implied, Cycles[0x22]},
-	0x32: {"NOP", "NOP", Implied, Cycles[0x32]},
-	0x42: {"NOP", "NOP", Implied, Cycles[0x42]},
-	0x52: {"NOP", "NOP", Implied, Cycles[0x52]},
-	0x62: {"NOP", "NOP", Implied, Cycles[0x62]},
-	0x6B: {"NOP", "NOP", Implied, Cycles[0x62]},   //ARR
-	0x0B: {"NOP", "NOP", Immediate, Cycles[0x0B]}, //ANC
-	0x72: {"NOP", "NOP", Implied, Cycles[0x72]},
-	0x92: {"NOP", "NOP", Implied, Cycles[0x92]},
-	0xB2: {"NOP", "NOP", Implied, Cycles[0xB2]},
-	0xD2: {"NOP", "NOP", Implied, Cycles[0xD2]},
-	0xF2: {"NOP", "NOP", Implied, Cycles[0xF2]},
-	0x80: {"NOPD", "NOPD", Implied, Cycles[0x80]},
-	0x82: {"NOPD", "NOPD", Implied, Cycles[0x82]},
-	0x89: {"NOPD", "NOPD", Implied, Cycles[0x89]},
-	0xC2: {"NOPD", "NOPD", Implied, Cycles[0xC2]},
-	0xCB: {"NOP", "NOP", Implied, Cycles[0xCB]}, //ASX
-	0x9F: {"NOP", "NOP", Implied, Cycles[0x9F]}, //AHX
-	0xE2: {"NOPD", "NOPD", Implied, Cycles[0xE2]},
-	0x04: {"NOPD", "NOPD", Implied, Cycles[0x04]},
-	0x44: {"NOPD", "NOPD", Implied, Cycles[0x44]},
-	0x64: {"NOPD", "NOPD", Implied, Cycles[0x64]},
-	0x14: {"NOPD", "NOPD", Implied, Cycles[0x14]},
-	0x34: {"NOPD", "NOPD", Implied, Cycles[0x34]},
-	0x54: {"NOPD", "NOPD", Implied, Cycles[0x54]},
-	0x74: {"NOPD", "NOPD", Implied, Cycles[0x74]},
-	0xD4: {"NOPD", "NOPD", Implied, Cycles[0xD4]},
-	0xF4: {"NOPD", "NOPD", Implied, Cycles[0xF4]},
-	0x0C: {"NOPI", "NOPI", Implied, Cycles[0x0C]},
-	0x1C: {"NOPI", "NOPI", Implied, Cycles[0x1C]},
-	0x3C: {"NOPI", "NOPI", Implied, Cycles[0x3C]},
-	0x5C: {"NOPI", "NOPI", Implied, Cycles[0x5C]},
-	0x7C: {"NOPI", "NOPI", Implied, Cycles[0x7C]},
-	0xDC: {"NOPI", "NOPI", Implied, Cycles[0xDC]},
-	0xFC: {"NOPI", "NOPI", Implied, Cycles[0xFC]},
+	0x80: {"NOP_IMM", "NOP", Immediate, Cycles[0x80]},
+	0x82: {"NOP_IMM", "NOP", Immediate, Cycles[0x82]},
+	0x89: {"NOP_IMM", "NOP", Immediate, Cycles[0x89]},
+	0xC2: {"NOP_IMM", "NOP", Immediate, Cycles[0xC2]},
+	0xE2: {"NOP_IMM", "NOP", Immediate, Cycles[0xE2]},
+	0x04: {"NOP_ZERO", "NOP", ZeroPage, Cycles[0x04]},
+	0x44: {"NOP_ZERO", "NOP", ZeroPage, Cycles[0x44]},
+	0x64: {"NOP_ZERO", "NOP", ZeroPage, Cycles[0x64]},
+	0x14: {"NOP_ZEROX", "NOP", ZeroPageX, Cycles[0x14]},
+	0x34: {"NOP_ZEROX", "NOP", ZeroPageX, Cycles[0x34]},
+	0x54: {"NOP_ZEROX", "NOP", ZeroPageX, Cycles[0x54]},
+	0x74: {"NOP_ZEROX", "NOP", ZeroPageX, Cycles[0x74]},
+	0xD4: {"NOP_ZEROX", "NOP", ZeroPageX, Cycles[0xD4]},
+	0xF4: {"NOP_ZEROX", "NOP", ZeroPageX, Cycles[0xF4]},
+	0x0C: {"NOP_ABS", "NOP", Absolute, Cycles[0x0C]},
+	0x1C: {"NOP_ABSX", "NOP", AbsoluteX, Cycles[0x1C]},
+	0x3C: {"NOP_ABSX", "NOP", AbsoluteX, Cycles[0x3C]},
+	0x5C: {"NOP_ABSX", "NOP", AbsoluteX, Cycles[0x5C]},
+	0x7C: {"NOP_ABSX", "NOP", AbsoluteX, Cycles[0x7C]},
+	0xDC: {"NOP_ABSX", "NOP", AbsoluteX, Cycles[0xDC]},
+	0xFC: {"NOP_ABSX", "NOP", AbsoluteX, Cycles[0xFC]},
 	// LAX
 	0xA7: {"LAX_ZERO", "LAX", ZeroPage, Cycles[0xA7]},
-	0xAB: {"LAX_IMM", "LAX", Immediate, Cycles[0xAB]}, // !!!
 	0xB7: {"LAX_ZEROY", "LAX", ZeroPageY, Cycles[0xB7]},
 	0xAF: {"LAX_ABS", "LAX", Absolute, Cycles[0xAF]},
 	0xBF: {"LAX_ABSY", "LAX", AbsoluteY, Cycles[0xBF]},
 	0xA3: {"LAX_INDX", "LAX", PreIndexedIndirect, Cycles[0xA3]},
 	0xB3: {"LAX_INDY", "LAX", PostIndexedIndirect, Cycles[0xB3]},
 	// LAS
-	0xBB: {"NOP", "NOP", AbsoluteY, Cycles[0xBB]},
-	// SHY
-	0x9C: {"NOP", "NOP", AbsoluteY, Cycles[0x9C]},
+	0xBB: {"LAS_ABSY", "LAS", AbsoluteY, Cycles[0xBB]},
+	// ANC, ALR, ARR, AXS (SBX)
+	0x0B: {"ANC_IMM", "ANC", Immediate, Cycles[0x0B]},
+	0x2B: {"ANC_IMM", "ANC", Immediate, Cycles[0x2B]},
+	0x4B: {"ALR_IMM", "ALR", Immediate, Cycles[0x4B]},
+	0x6B: {"ARR_IMM", "ARR", Immediate, Cycles[0x6B]},
+	0xCB: {"AXS_IMM", "AXS", Immediate, Cycles[0xCB]},
+	// Unstable: XAA (ANE) and LAX immediate (LXA) mix in an analog "magic" constant
+	0x8B: {"XAA_IMM", "XAA", Immediate, Cycles[0x8B]},
+	0xAB: {"LXA_IMM", "LXA", Immediate, Cycles[0xAB]},
+	// Unstable: SHY, SHX, TAS (SHS), AHX (SHA) store the register ANDed with the address high byte + 1
+	0x9C: {"SHY_ABSX", "SHY", AbsoluteX, Cycles[0x9C]},
+	0x9E: {"SHX_ABSY", "SHX", AbsoluteY, Cycles[0x9E]},
+	0x9B: {"TAS_ABSY", "TAS", AbsoluteY, Cycles[0x9B]},
+	0x9F: {"AHX_ABSY", "AHX", AbsoluteY, Cycles[0x9F]},
+	0x93: {"AHX_INDY", "AHX", PostIndexedIndirect, Cycles[0x93]},
+	// JAM (KIL) halts the CPU
+	0x02: {"JAM", "JAM", Implied, Cycles[0x02]},
+	0x12: {"JAM", "JAM", Implied, Cycles[0x12]},
+	0x22: {"JAM", "JAM", Implied, Cycles[0x22]},
+	0x32: {"JAM", "JAM", Implied, Cycles[0x32]},
+	0x42: {"JAM", "JAM", Implied, Cycles[0x42]},
+	0x52: {"JAM", "JAM", Implied, Cycles[0x52]},
+	0x62: {"JAM", "JAM", Implied, Cycles[0x62]},
+	0x72: {"JAM", "JAM", Implied, Cycles[0x72]},
+	0x92: {"JAM", "JAM", Implied, Cycles[0x92]},
+	0xB2: {"JAM", "JAM", Implied, Cycles[0xB2]},
+	0xD2: {"JAM", "JAM", Implied, Cycles[0xD2]},
+	0xF2: {"JAM", "JAM", Implied, Cycles[0xF2]},
 	// SAX
 	0x87: {"SAX_ZERO", "SAX", ZeroPage, Cycles[0x87]},
 	0x97: {"SAX_ZEROY", "SAX", ZeroPageY, Cycles[0x97]},
@@ -247,7 +265,7 @@ var OpCodes = map[uint]OpCode{
 	0xD7: {"DCP_ZEROX", "DCP", ZeroPageX, Cycles[0xD7]},
 	0xCF: {"DCP_ABS", "DCP", Absolute, Cycles[0xCF]},
 	0xDF: {"DCP_ABSX", "DCP", AbsoluteX, Cycles[0xDF]},
-	0xDB: {"DCP_ABSY", "DCP", AbsoluteY, Cycles[0xD8]},
+	0xDB: {"DCP_ABSY", "DCP", AbsoluteY, Cycles[0xDB]},
 	0xC3: {"DCP_INDX", "DCP", PreIndexedIndirect, Cycles[0xC3]},
 	0xD3: {"DCP_INDY", "DCP", PostIndexedIndirect, Cycles[0xD3]},
 	// ISB
@@ -255,7 +273,7 @@ var OpCodes = map[uint]OpCode{
 	0xF7: {"ISB_ZEROX", "ISB", ZeroPageX, Cycles[0xF7]},
 	0xEF: {"ISB_ABS", "ISB", Absolute, Cycles[0xEF]},
 	0xFF: {"ISB_ABSX", "ISB", AbsoluteX, Cycles[0xFF]},
-	0xFB: {"ISB_ABSY", "ISB", AbsoluteY, Cycles[0xF8]},
+	0xFB: {"ISB_ABSY", "ISB", AbsoluteY, Cycles[0xFB]},
 	0xE3: {"ISB_INDX", "ISB", PreIndexedIndirect, Cycles[0xE3]},
 	0xF3: {"ISB_INDY", "ISB", PostIndexedIndirect, Cycles[0xF3]},
 	// SLO
@@ -291,3 +309,101 @@ var OpCodes = map[uint]OpCode{
 	0x63: {"RRA_INDX", "RRA", PreIndexedIndirect, Cycles[0x63]},
 	0x73: {"RRA_INDY", "RRA", PostIndexedIndirect, Cycles[0x73]},
 }
+
+type instruction struct {
+	addressing Addressing
+	isWrite    bool
+	handler    func(C *Cpu, mode Addressing, addrOrData uint)
+}
+
+var handlers = map[string]func(C *Cpu, mode Addressing, addrOrData uint){
+	"LDA": (*Cpu).lda,
+	"LDX": (*Cpu).ldx,
+	"LDY": (*Cpu).ldy,
+	"STA": (*Cpu).sta,
+	"STX": (*Cpu).stx,
+	"STY": (*Cpu).sty,
+	"TAX": (*Cpu).tax,
+	"TAY": (*Cpu).tay,
+	"TSX": (*Cpu).tsx,
+	"TXA": (*Cpu).txa,
+	"TXS": (*Cpu).txs,
+	"TYA": (*Cpu).tya,
+	"ADC": (*Cpu).adc,
+	"AND": (*Cpu).and,
+	"ASL": (*Cpu).asl,
+	"BIT": (*Cpu).bit,
+	"CMP": (*Cpu).cmp,
+	"CPX": (*Cpu).cpx,
+	"CPY": (*Cpu).cpy,
+	"DEC": (*Cpu).dec,
+	"DEX": (*Cpu).dex,
+	"DEY": (*Cpu).dey,
+	"EOR": (*Cpu).eor,
+	"INC": (*Cpu).inc,
+	"INX": (*Cpu).inx,
+	"INY": (*Cpu).iny,
+	"LSR": (*Cpu).lsr,
+	"ORA": (*Cpu).ora,
+	"ROL": (*Cpu).rol,
+	"ROR": (*Cpu).ror,
+	"SBC": (*Cpu).sbc,
+	"PHA": (*Cpu).pha,
+	"PHP": (*Cpu).php,
+	"PLA": (*Cpu).pla,
+	"PLP": (*Cpu).plp,
+	"JMP": (*Cpu).jmp,
+	"JSR": (*Cpu).jsr,
+	"RTS": (*Cpu).rts,
+	"RTI": (*Cpu).rti,
+	"BCC": (*Cpu).bcc,
+	"BCS": (*Cpu).bcs,
+	"BEQ": (*Cpu).beq,
+	"BMI": (*Cpu).bmi,
+	"BNE": (*Cpu).bne,
+	"BPL": (*Cpu).bpl,
+	"BVS": (*Cpu).bvs,
+	"BVC": (*Cpu).bvc,
+	"CLD": (*Cpu).cld,
+	"CLC": (*Cpu).clc,
+	"CLI": (*Cpu).cli,
+	"CLV": (*Cpu).clv,
+	"SEC": (*Cpu).sec,
+	"SEI": (*Cpu).sei,
+	"SED": (*Cpu).sed,
+	"BRK": (*Cpu).brk,
+	"NOP": (*Cpu).nop,
+	"LAX": (*Cpu).lax,
+	"SAX": (*Cpu).sax,
+	"DCP": (*Cpu).dcp,
+	"ISB": (*Cpu).isb,
+	"SLO": (*Cpu).slo,
+	"RLA": (*Cpu).rla,
+	"SRE": (*Cpu).sre,
+	"RRA": (*Cpu).rra,
+	"ANC": (*Cpu).anc,
+	"ALR": (*Cpu).alr,
+	"ARR": (*Cpu).arr,
+	"AXS": (*Cpu).axs,
+	"XAA": (*Cpu).xaa,
+	"LXA": (*Cpu).lxa,
+	"LAS": (*Cpu).las,
+	"SHY": (*Cpu).shy,
+	"SHX": (*Cpu).shx,
+	"TAS": (*Cpu).tas,
+	"AHX": (*Cpu).ahx,
+	"JAM": (*Cpu).jam,
+}
+
+// Decoded OpCodes, indexed by the opcode byte. All 256 opcodes are defined.
+var instructions [0x100]instruction
+
+func init() {
+	for op, opCode := range OpCodes {
+		instructions[op] = instruction{
+			opCode.Addressing,
+			WriteInstructions[opCode.BaseName],
+			handlers[opCode.BaseName],
+		}
+	}
+}
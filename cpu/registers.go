@@ -1,5 +1,7 @@
 package cpu
 
+import . "github.com/popsul/gones/common"
+
 // Register's names
 const (
 	RA  = 0x00
@@ -48,6 +50,18 @@ func NewStatus(
 	}
 }
 
+// Byte packs the flags as they are pushed, NV1BDIZC.
+func (S *Status) Byte() byte {
+	return byte(B2i(S.Negative)<<7 |
+		B2i(S.Overflow)<<6 |
+		B2i(S.Reserved)<<5 |
+		B2i(S.BreakMode)<<4 |
+		B2i(S.DecimalMode)<<3 |
+		B2i(S.Interrupt)<<2 |
+		B2i(S.Zero)<<1 |
+		B2i(S.Carry))
+}
+
 func NewRegisters() *Registers {
 	return &Registers{
 		0x00,
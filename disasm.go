@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/popsul/gones/disasm"
+	"github.com/popsul/gones/interrupts"
+	"github.com/popsul/gones/mapper"
+	"github.com/popsul/gones/reader"
+	"os"
+	"strconv"
+)
+
+// runDisasm implements "gones disasm", it prints the code as mapped right after power on.
+func runDisasm(args []string) int {
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	entry := flags.String("entry", "", "file to load from a .zip archive")
+	patch := flags.String("patch", "", "IPS/UPS/BPS patch to apply")
+	start := flags.String("start", "", "start address, e.g. 0xC000 (default: reset vector)")
+	count := flags.Int("count", 32, "number of instructions")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s disasm [options] <file>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	rom, err := reader.Load(flags.Arg(0), *entry, *patch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	m, err := mapper.NewMapper(rom, interrupts.NewInterrupts())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	// NOTE: Reads through the mapper have no side effects, unlike the CPU bus.
+	memory := disasm.ReaderFunc(m.ReadByCpu)
+
+	var addr uint
+	if *start == "" {
+		addr = uint(memory.Read(0xFFFC)) | uint(memory.Read(0xFFFD))<<8
+	} else {
+		value, err := strconv.ParseUint(*start, 0, 16)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		addr = uint(value)
+	}
+
+	for _, inst := range disasm.Disassemble(memory, addr, *count) {
+		fmt.Printf("%04X  %-8s  %s\n", inst.Addr, inst.HexBytes(), inst)
+	}
+	return 0
+}
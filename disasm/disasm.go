@@ -0,0 +1,208 @@
+package disasm
+
+import (
+	"fmt"
+	"github.com/popsul/gones/cpu"
+	"strings"
+)
+
+/*
+	Operand syntax
+
+	| addressing          | bytes | syntax      |
+	+---------------------+-------+-------------+
+	| implied             |  1    | CLC         |
+	| accumulator         |  1    | ASL A       |
+	| immediate           |  2    | LDA #$20    |
+	| zero page           |  2    | LDA $20     |
+	| zero page,X/Y       |  2    | LDA $20,X   |
+	| absolute            |  3    | LDA $1234   |
+	| absolute,X/Y        |  3    | LDA $1234,X |
+	| (indirect,X)        |  2    | LDA ($20,X) |
+	| (indirect),Y        |  2    | LDA ($20),Y |
+	| indirect            |  3    | JMP ($1234) |
+	| relative            |  2    | BNE $C004   |
+
+	Relative operands are shown as the branch target.
+*/
+
+// Reader is the memory to disassemble. Reads must not have side effects,
+// so pass RAM, ROM or a mapper (see. ReaderFunc) rather than a live CPU bus.
+type Reader interface {
+	Read(addr uint) byte
+}
+
+// ReaderFunc adapts a function (e.g. Mapper.ReadByCpu) to a Reader.
+type ReaderFunc func(addr uint) byte
+
+func (F ReaderFunc) Read(addr uint) byte {
+	return F(addr)
+}
+
+// Bytes is a Reader for a byte slice loaded at Origin, it reads 0 outside of the slice.
+type Bytes struct {
+	Origin uint
+	Data   []byte
+}
+
+func (B *Bytes) Read(addr uint) byte {
+	if addr < B.Origin || addr-B.Origin >= uint(len(B.Data)) {
+		return 0
+	}
+	return B.Data[addr-B.Origin]
+}
+
+type Instruction struct {
+	Addr       uint
+	Bytes      []byte
+	Name       string
+	Addressing cpu.Addressing
+	// Operand byte or word as it is encoded, the branch offset for relative addressing.
+	Operand uint
+	// Unofficial opcodes are prefixed with '*' in nestest style traces.
+	IsUnofficial bool
+}
+
+// Decode disassembles the instruction at addr.
+func Decode(r Reader, addr uint) Instruction {
+	addr &= 0xFFFF
+	opcode := uint(r.Read(addr))
+	op := cpu.OpCodes[opcode]
+	size := Size(op.Addressing)
+	inst := Instruction{
+		Addr:         addr,
+		Bytes:        make([]byte, size),
+		Name:         op.BaseName,
+		Addressing:   op.Addressing,
+		IsUnofficial: IsUnofficial(opcode),
+	}
+	for i := uint(0); i < size; i++ {
+		inst.Bytes[i] = r.Read((addr + i) & 0xFFFF)
+	}
+	if size == 2 {
+		inst.Operand = uint(inst.Bytes[1])
+	} else if size == 3 {
+		inst.Operand = uint(inst.Bytes[1]) | uint(inst.Bytes[2])<<8
+	}
+	return inst
+}
+
+// Disassemble decodes count instructions from addr.
+func Disassemble(r Reader, addr uint, count int) []Instruction {
+	list := make([]Instruction, 0, count)
+	for i := 0; i < count; i++ {
+		inst := Decode(r, addr)
+		list = append(list, inst)
+		addr = inst.Next()
+	}
+	return list
+}
+
+// DisassembleBytes decodes the whole slice as code loaded at origin.
+func DisassembleBytes(data []byte, origin uint) []Instruction {
+	r := &Bytes{origin, data}
+	var list []Instruction
+	for addr := origin; addr < origin+uint(len(data)); {
+		inst := Decode(r, addr)
+		list = append(list, inst)
+		addr += inst.Size()
+	}
+	return list
+}
+
+func Size(mode cpu.Addressing) uint {
+	switch mode {
+	case cpu.Implied, cpu.Accumulator:
+		return 1
+	case cpu.Absolute, cpu.AbsoluteX, cpu.AbsoluteY, cpu.IndirectAbsolute:
+		return 3
+	}
+	return 2
+}
+
+// IsUnofficial reports opcodes outside of the 151 documented ones.
+func IsUnofficial(opcode uint) bool {
+	switch opcode {
+	case 0x1A, 0x3A, 0x5A, 0x7A, 0xDA, 0xFA, 0xEB:
+		// NOP and SBC duplicates
+		return true
+	}
+	op := cpu.OpCodes[opcode]
+	if op.BaseName == "NOP" {
+		return op.Addressing != cpu.Implied
+	}
+	return !officialNames[op.BaseName]
+}
+
+var officialNames = map[string]bool{
+	"ADC": true, "AND": true, "ASL": true, "BCC": true, "BCS": true, "BEQ": true, "BIT": true, "BMI": true,
+	"BNE": true, "BPL": true, "BRK": true, "BVC": true, "BVS": true, "CLC": true, "CLD": true, "CLI": true,
+	"CLV": true, "CMP": true, "CPX": true, "CPY": true, "DEC": true, "DEX": true, "DEY": true, "EOR": true,
+	"INC": true, "INX": true, "INY": true, "JMP": true, "JSR": true, "LDA": true, "LDX": true, "LDY": true,
+	"LSR": true, "NOP": true, "ORA": true, "PHA": true, "PHP": true, "PLA": true, "PLP": true, "ROL": true,
+	"ROR": true, "RTI": true, "RTS": true, "SBC": true, "SEC": true, "SED": true, "SEI": true, "STA": true,
+	"STX": true, "STY": true, "TAX": true, "TAY": true, "TSX": true, "TXA": true, "TXS": true, "TYA": true,
+}
+
+func (I Instruction) Size() uint {
+	return uint(len(I.Bytes))
+}
+
+// Next returns the address of the following instruction.
+func (I Instruction) Next() uint {
+	return (I.Addr + I.Size()) & 0xFFFF
+}
+
+// Target returns the destination of a branch.
+func (I Instruction) Target() uint {
+	return (I.Next() + uint(int8(I.Operand))) & 0xFFFF
+}
+
+// HexBytes returns the encoded instruction, e.g. "4C F5 C5".
+func (I Instruction) HexBytes() string {
+	hex := make([]string, len(I.Bytes))
+	for i, b := range I.Bytes {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, " ")
+}
+
+// OperandString returns the operand in standard syntax, e.g. "($20),Y".
+func (I Instruction) OperandString() string {
+	switch I.Addressing {
+	case cpu.Accumulator:
+		return "A"
+	case cpu.Immediate:
+		return fmt.Sprintf("#$%02X", I.Operand)
+	case cpu.ZeroPage:
+		return fmt.Sprintf("$%02X", I.Operand)
+	case cpu.ZeroPageX:
+		return fmt.Sprintf("$%02X,X", I.Operand)
+	case cpu.ZeroPageY:
+		return fmt.Sprintf("$%02X,Y", I.Operand)
+	case cpu.Absolute:
+		return fmt.Sprintf("$%04X", I.Operand)
+	case cpu.AbsoluteX:
+		return fmt.Sprintf("$%04X,X", I.Operand)
+	case cpu.AbsoluteY:
+		return fmt.Sprintf("$%04X,Y", I.Operand)
+	case cpu.PreIndexedIndirect:
+		return fmt.Sprintf("($%02X,X)", I.Operand)
+	case cpu.PostIndexedIndirect:
+		return fmt.Sprintf("($%02X),Y", I.Operand)
+	case cpu.IndirectAbsolute:
+		return fmt.Sprintf("($%04X)", I.Operand)
+	case cpu.Relative:
+		return fmt.Sprintf("$%04X", I.Target())
+	}
+	return ""
+}
+
+// String returns the instruction in standard syntax, e.g. "LDA ($20),Y".
+func (I Instruction) String() string {
+	operand := I.OperandString()
+	if operand == "" {
+		return I.Name
+	}
+	return I.Name + " " + operand
+}
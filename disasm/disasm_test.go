@@ -0,0 +1,56 @@
+package disasm
+
+import "testing"
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name       string
+		code       []byte
+		text       string
+		unofficial bool
+	}{
+		{"implied", []byte{0x18}, "CLC", false},
+		{"accumulator", []byte{0x0A}, "ASL A", false},
+		{"immediate", []byte{0xA9, 0x20}, "LDA #$20", false},
+		{"zero page", []byte{0xA5, 0x20}, "LDA $20", false},
+		{"zero page,X", []byte{0xB5, 0x20}, "LDA $20,X", false},
+		{"zero page,Y", []byte{0xB6, 0x20}, "LDX $20,Y", false},
+		{"absolute", []byte{0xAD, 0x34, 0x12}, "LDA $1234", false},
+		{"absolute,X", []byte{0xBD, 0x34, 0x12}, "LDA $1234,X", false},
+		{"absolute,Y", []byte{0xB9, 0x34, 0x12}, "LDA $1234,Y", false},
+		{"(indirect,X)", []byte{0xA1, 0x20}, "LDA ($20,X)", false},
+		{"(indirect),Y", []byte{0xB1, 0x20}, "LDA ($20),Y", false},
+		{"indirect", []byte{0x6C, 0x34, 0x12}, "JMP ($1234)", false},
+		{"relative forward", []byte{0xD0, 0x02}, "BNE $C004", false},
+		{"relative backward", []byte{0xD0, 0xFE}, "BNE $C000", false},
+		{"official NOP", []byte{0xEA}, "NOP", false},
+		{"implied NOP", []byte{0x1A}, "NOP", true},
+		{"immediate NOP", []byte{0x80, 0x20}, "NOP #$20", true},
+		{"zero page NOP", []byte{0x04, 0x20}, "NOP $20", true},
+		{"absolute,X NOP", []byte{0x1C, 0x34, 0x12}, "NOP $1234,X", true},
+		{"SBC duplicate", []byte{0xEB, 0x20}, "SBC #$20", true},
+		{"LAX zero page,Y", []byte{0xB7, 0x20}, "LAX $20,Y", true},
+		{"SAX (indirect,X)", []byte{0x83, 0x20}, "SAX ($20,X)", true},
+		{"DCP (indirect),Y", []byte{0xD3, 0x20}, "DCP ($20),Y", true},
+		{"ISB absolute,Y", []byte{0xFB, 0x34, 0x12}, "ISB $1234,Y", true},
+	}
+	for _, test := range tests {
+		inst := Decode(&Bytes{0xC000, test.code}, 0xC000)
+		if inst.String() != test.text || inst.Size() != uint(len(test.code)) {
+			t.Errorf("%s: %q (%d bytes), want %q (%d bytes)", test.name, inst.String(), inst.Size(), test.text, len(test.code))
+		}
+		if inst.IsUnofficial != test.unofficial {
+			t.Errorf("%s: unofficial %t, want %t", test.name, inst.IsUnofficial, test.unofficial)
+		}
+	}
+}
+
+func TestDisassembleBytes(t *testing.T) {
+	list := DisassembleBytes([]byte{0x4C, 0xF5, 0xC5, 0xA1, 0x80, 0x60}, 0xC000)
+	if len(list) != 3 {
+		t.Fatalf("%d instructions, want 3", len(list))
+	}
+	if list[0].HexBytes() != "4C F5 C5" || list[1].Addr != 0xC003 || list[2].Next() != 0xC006 {
+		t.Errorf("%s at %04X, next %04X", list[0].HexBytes(), list[1].Addr, list[2].Next())
+	}
+}
@@ -1,8 +1,32 @@
 package interrupts
 
+/*
+	NMI and IRQ lines of the CPU
+
+	NMI is edge triggered: the CPU remembers a rising edge of the line until it is serviced.
+	IRQ is level triggered and wired-OR: it stays asserted while any source holds it,
+	each source releases its own bit when the program acknowledges it.
+
+	| source       | acknowledged by                           |
+	+--------------+-------------------------------------------+
+	| APU frame    | reading 0x4015, setting 0x4017 bit 6      |
+	| DMC          | reading/writing 0x4015                    |
+	| mapper       | mapper specific (e.g. MMC3 0xE000)        |
+	| external     | expansion port devices                    |
+*/
+
+type IrqSource uint
+
+const (
+	IrqApuFrame = IrqSource(1 << iota)
+	IrqDmc
+	IrqMapper
+	IrqExternal
+)
+
 type Interrupts struct {
 	nmi bool
-	irq bool
+	irq IrqSource
 }
 
 func NewInterrupts() *Interrupts {
@@ -11,7 +35,7 @@ func NewInterrupts() *Interrupts {
 
 func (I *Interrupts) init() *Interrupts {
 	I.nmi = false
-	I.irq = false
+	I.irq = 0
 	return I
 }
 
@@ -20,6 +44,11 @@ func (I *Interrupts) IsNmiAssert() bool {
 }
 
 func (I *Interrupts) IsIrqAssert() bool {
+	return I.irq != 0
+}
+
+// IrqSources returns the sources currently holding the IRQ line.
+func (I *Interrupts) IrqSources() IrqSource {
 	return I.irq
 }
 
@@ -31,10 +60,10 @@ func (I *Interrupts) ReleaseNmi() {
 	I.nmi = false
 }
 
-func (I *Interrupts) AssertIrq() {
-	I.irq = true
+func (I *Interrupts) AssertIrq(source IrqSource) {
+	I.irq |= source
 }
 
-func (I *Interrupts) ReleaseIrq() {
-	I.irq = false
+func (I *Interrupts) ReleaseIrq(source IrqSource) {
+	I.irq &^= source
 }
@@ -1,101 +1,229 @@
 package main
 
 import (
-	"github.com/popsul/gones/apu"
-	"github.com/popsul/gones/bus"
+	"bytes"
+	"flag"
+	"fmt"
 	"github.com/popsul/gones/common"
-	"github.com/popsul/gones/cpu"
-	"github.com/popsul/gones/interrupts"
+	"github.com/popsul/gones/console"
+	"github.com/popsul/gones/disasm"
 	"github.com/popsul/gones/ppu"
 	"github.com/popsul/gones/reader"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
+	"syscall"
 	"time"
 )
 
-type Nes struct {
-	ppu *ppu.Ppu
-
-	cpu        *cpu.Cpu
-	dma        *cpu.Dma
-	interrupts *interrupts.Interrupts
-
-	cpuBus       *cpu.CpuBus
-	ram          *bus.Ram
-	ppuBus       *bus.PpuBus
-	characterMem *bus.Ram
-	programPom   *bus.Rom
-	keypad1      *bus.Keypad
-	keypad2      *bus.Keypad
-	apu          *apu.Apu
+const saveInterval = 5 * time.Second
 
+type Nes struct {
+	console  *console.Console
 	renderer *ppu.Renderer
+
+	savePath string
+	saved    []byte
 }
 
-func NewNes(rom *reader.NesRom) *Nes {
+func NewNes(rom *reader.NesRom) (*Nes, error) {
 	nes := new(Nes)
 
-	nes.keypad1 = bus.NewKeypad()
-	nes.keypad2 = bus.NewKeypad()
-	nes.ram = bus.NewRam(2048)
-
-	nes.characterMem = bus.NewRam(0x4000)
-	nes.characterMem.Fill(rom.Character)
-
-	nes.programPom = bus.NewRom(rom.Program)
-
-	nes.ppuBus = bus.NewPpuBus(nes.characterMem)
-	nes.interrupts = interrupts.NewInterrupts()
-
-	nes.ppu = ppu.NewPpu(nes.ppuBus, nes.interrupts, rom.HorizontalMirror)
-	nes.dma = cpu.NewDma(nes.ram, nes.ppu)
-
-	nes.apu = apu.NewApu(nes.interrupts)
-
-	nes.cpuBus = cpu.NewCpuBus(nes.ram, nes.programPom, nes.ppu, nes.apu, nes.keypad1, nes.keypad2, nes.dma)
-	nes.cpu = cpu.NewCpu(nes.cpuBus, nes.interrupts)
-	nes.cpu.Reset()
+	c, err := console.NewConsole(rom)
+	if err != nil {
+		return nil, err
+	}
+	nes.console = c
+	nes.console.Apu().Play()
 
-	nes.renderer = ppu.NewRenderer(nes.keypad1)
+	nes.renderer = ppu.NewRenderer(nes.console.Keypad1())
 
-	return nes
+	return nes, nil
 }
 
 func (N *Nes) Frame(deadline float64) {
 	allowedCycles := deadline / 1000 / 1000 / 1000 * float64(common.CpuClock)
 	for allowedCycles > 0 {
-		var cycle uint = 0
-		if N.dma.IsDmaProcessing() {
-			N.dma.Run()
-			cycle = 514
-		}
-		cpuCycles := N.cpu.Run()
+		// NOTE: The CPU ticks the PPU and the APU on each of its bus accesses.
+		cpuCycles := N.console.Run()
 		allowedCycles -= float64(cpuCycles)
-		cycle += cpuCycles
-		renderingData := N.ppu.Run(cycle * 3)
-		N.apu.Run(cycle)
-		if renderingData != nil {
+		if renderingData := N.console.RenderingData(); renderingData != nil {
 			N.renderer.Render(renderingData)
+			if N.renderer.IsResetRequested() {
+				N.console.Reset()
+			}
 			break
 		}
 	}
 }
 
+// Jammed returns the CPU state when a JAM opcode halted it, nil otherwise.
+func (N *Nes) Jammed() error {
+	return N.console.Jammed()
+}
+
+func (N *Nes) IsQuit() bool {
+	return N.renderer.IsQuit()
+}
+
+// LoadSave restores battery backed PRG-RAM from the file, a missing file is not an error.
+func (N *Nes) LoadSave(path string) error {
+	if !N.console.Mapper().HasBattery() {
+		return nil
+	}
+	N.savePath = path
+	ram := N.console.Mapper().ProgramRam()
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		copy(ram, data)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	N.saved = append([]byte{}, ram...)
+	return nil
+}
+
+// FlushSave writes battery backed PRG-RAM when it has changed since the last flush.
+func (N *Nes) FlushSave() error {
+	if N.savePath == "" {
+		return nil
+	}
+	ram := N.console.Mapper().ProgramRam()
+	if bytes.Equal(ram, N.saved) {
+		return nil
+	}
+	// NOTE: Write to a temporary file first, so a crash while saving does not destroy the previous save.
+	tmp := N.savePath + ".tmp"
+	if err := ioutil.WriteFile(tmp, ram, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, N.savePath); err != nil {
+		return err
+	}
+	N.saved = append(N.saved[:0], ram...)
+	return nil
+}
+
+// Dump prints the registers and the next instructions from PC.
 func (N *Nes) Dump() {
-	N.cpu.Dump()
+	registers := N.console.Cpu().Registers()
+	fmt.Printf(
+		"PC: 0x%04x A: 0x%02x X: 0x%02x Y: 0x%02x SP: 0x%04x\n",
+		registers.PC,
+		registers.A,
+		registers.X,
+		registers.Y,
+		registers.SP,
+	)
+	for _, inst := range disasm.Disassemble(disasm.ReaderFunc(N.console.Peek), registers.PC, 8) {
+		fmt.Printf("0x%04x\t%-8s\t%s\n", inst.Addr, inst.HexBytes(), inst)
+	}
 }
 
 func main() {
-	var nesFile = os.Args[1]
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "disasm":
+			os.Exit(runDisasm(os.Args[2:]))
+		case "test":
+			os.Exit(runTest(os.Args[2:]))
+		}
+	}
+	entry := flag.String("entry", "", "file to load from a .zip archive (default: first .nes or .unf file)")
+	patch := flag.String("patch", "", "IPS/UPS/BPS patch to apply (default: patch next to the ROM)")
+	gameDb := flag.String("gamedb", "", "game database correcting bad headers (.json or .xml, e.g. a NesCartDB export)")
+	traceFile := flag.String("trace", "", "write a nestest.log style CPU trace to the file (- for stdout)")
+	traceRange := flag.String("trace-range", "", "only trace instructions in the address range, e.g. 0xC000-0xC5FF")
+	traceRing := flag.Int("trace-ring", 0, "keep the last N trace lines and only write them when the CPU crashes")
+	noSpriteLimit := flag.Bool("no-sprite-limit", false, "draw more than 8 sprites per line to reduce flicker")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] <file.nes|file.unf|file.zip|file.gz>\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s disasm [options] <file>\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s test [options] <file>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var nesFile = flag.Arg(0)
 	println("input file: ", nesFile)
 
-	rom := reader.ReadRom(nesFile)
-	nes := NewNes(rom)
+	if *gameDb != "" {
+		if err := reader.LoadGameDatabase(*gameDb); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	rom, err := reader.Load(nesFile, *entry, *patch)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	rom.Dump()
+
+	nes, err := NewNes(rom)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	nes.console.Ppu().SetSpriteLimit(!*noSpriteLimit)
+	if err := nes.LoadSave(strings.TrimSuffix(nesFile, filepath.Ext(nesFile)) + ".sav"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	tracing, err := startTracing(nes, *traceFile, *traceRange, *traceRing)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tracing.Crash()
+			tracing.Close()
+			panic(r)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
 	timestamp := time.Now().UnixNano()
-	for true {
+	lastSave := time.Now()
+	for !nes.IsQuit() {
+		select {
+		case <-signals:
+			flushSave(nes)
+			tracing.Close()
+			os.Exit(0)
+		default:
+		}
 		now := time.Now().UnixNano()
 		nes.Frame(float64(now - timestamp))
 		timestamp = now
+		if err := nes.Jammed(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			flushSave(nes)
+			tracing.Crash()
+			tracing.Close()
+			os.Exit(1)
+		}
+		if time.Since(lastSave) >= saveInterval {
+			flushSave(nes)
+			lastSave = time.Now()
+		}
 		runtime.Gosched()
 	}
+	flushSave(nes)
+	tracing.Close()
+}
+
+func flushSave(nes *Nes) {
+	if err := nes.FlushSave(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
 }
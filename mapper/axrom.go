@@ -0,0 +1,48 @@
+package mapper
+
+// AxROM (mapper 7): switchable 32K PRG bank, 8K CHR-RAM, single-screen mirroring.
+// Writes to 0x8000-0xFFFF: bits 0-2 select the PRG bank, bit 4 the nametable page.
+type Axrom struct {
+	*Cartridge
+	programBank uint
+}
+
+func NewAxrom(cartridge *Cartridge) *Axrom {
+	axrom := new(Axrom)
+	axrom.Cartridge = cartridge
+	axrom.PowerOn()
+	return axrom
+}
+
+func (A *Axrom) PowerOn() {
+	A.Cartridge.PowerOn()
+	A.programBank = 0
+	A.setMirroring(MirroringSingleScreenA)
+}
+
+func (A *Axrom) ReadByCpu(addr uint) byte {
+	if addr >= 0x8000 {
+		return A.readProgram(A.programBank, 0x8000, addr)
+	}
+	return 0
+}
+
+func (A *Axrom) WriteByCpu(addr uint, data byte) {
+	if addr < 0x8000 {
+		return
+	}
+	A.programBank = uint(data & 0x07)
+	if data&0x10 > 0 {
+		A.setMirroring(MirroringSingleScreenB)
+	} else {
+		A.setMirroring(MirroringSingleScreenA)
+	}
+}
+
+func (A *Axrom) ReadByPpu(addr uint) byte {
+	return A.readCharacter(0, 0x2000, addr)
+}
+
+func (A *Axrom) WriteByPpu(addr uint, data byte) {
+	A.writeCharacter(0, 0x2000, addr, data)
+}
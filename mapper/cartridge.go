@@ -0,0 +1,144 @@
+package mapper
+
+import (
+	"github.com/popsul/gones/interrupts"
+	"github.com/popsul/gones/reader"
+)
+
+const PROGRAM_RAM_SIZE = 0x2000
+const CHARACTER_RAM_SIZE = 0x2000
+
+// Cartridge holds the memories shared by all boards, mappers only decide
+// which bank of them is visible at a given address.
+type Cartridge struct {
+	program         []byte
+	trainer         []byte
+	character       []byte
+	programRam      []byte
+	isCharacterRam  bool
+	hasBattery      bool
+	mirroring       Mirroring
+	headerMirroring Mirroring
+	vram            []byte
+	interrupts      *interrupts.Interrupts
+}
+
+func NewCartridge(rom *reader.NesRom, interrupts *interrupts.Interrupts) *Cartridge {
+	cartridge := new(Cartridge)
+	cartridge.program = rom.Program
+	cartridge.trainer = rom.Trainer
+	if len(rom.Character) > 0 {
+		cartridge.character = rom.Character
+	} else {
+		// NOTE: Boards without CHR ROM have 8K CHR-RAM unless a NES 2.0 header says otherwise.
+		size := rom.CharacterRamSize + rom.CharacterNvramSize
+		if size == 0 {
+			size = CHARACTER_RAM_SIZE
+		}
+		cartridge.character = make([]byte, size)
+		cartridge.isCharacterRam = true
+	}
+	// NOTE: Boards mixing volatile and battery backed PRG-RAM are not supported, the whole RAM is saved.
+	cartridge.programRam = make([]byte, rom.ProgramRamSize+rom.ProgramNvramSize)
+	cartridge.hasBattery = rom.Battery && rom.ProgramNvramSize > 0
+	cartridge.interrupts = interrupts
+	switch {
+	case rom.FourScreen:
+		// INFO: Four-screen boards carry 2K VRAM for the nametables 2 and 3.
+		cartridge.headerMirroring = MirroringFourScreen
+		cartridge.vram = make([]byte, 2*NAMETABLE_SIZE)
+	case rom.SingleScreen && rom.SingleScreenPage == 1:
+		cartridge.headerMirroring = MirroringSingleScreenB
+	case rom.SingleScreen:
+		cartridge.headerMirroring = MirroringSingleScreenA
+	case rom.HorizontalMirror:
+		cartridge.headerMirroring = MirroringHorizontal
+	default:
+		cartridge.headerMirroring = MirroringVertical
+	}
+	cartridge.PowerOn()
+	return cartridge
+}
+
+func (C *Cartridge) PowerOn() {
+	if !C.hasBattery {
+		zero(C.programRam)
+	}
+	if C.isCharacterRam {
+		zero(C.character)
+	}
+	zero(C.vram)
+	// INFO: Trainer is loaded to 0x7000-0x71FF
+	if len(C.programRam) >= 0x1000+reader.TRAINER_SIZE {
+		copy(C.programRam[0x1000:], C.trainer)
+	}
+	C.mirroring = C.headerMirroring
+}
+
+func (C *Cartridge) Reset() {
+}
+
+func zero(memory []byte) {
+	for i := range memory {
+		memory[i] = 0
+	}
+}
+
+func (C *Cartridge) Mirroring() Mirroring {
+	return C.mirroring
+}
+
+func (C *Cartridge) NametablePage(table uint) uint {
+	return C.mirroring.Page(table)
+}
+
+func (C *Cartridge) CartridgeVram() []byte {
+	return C.vram
+}
+
+// Mirroring registers have no effect on four-screen boards.
+func (C *Cartridge) setMirroring(mirroring Mirroring) {
+	if C.mirroring == MirroringFourScreen {
+		return
+	}
+	C.mirroring = mirroring
+}
+
+func (C *Cartridge) HasBattery() bool {
+	return C.hasBattery
+}
+
+func (C *Cartridge) ProgramRam() []byte {
+	return C.programRam
+}
+
+// Bank numbers wrap around the available memory like a board with unconnected high address lines.
+func (C *Cartridge) readProgram(bank uint, size uint, addr uint) byte {
+	return C.program[(bank*size+addr%size)%uint(len(C.program))]
+}
+
+func (C *Cartridge) readCharacter(bank uint, size uint, addr uint) byte {
+	return C.character[(bank*size+addr%size)%uint(len(C.character))]
+}
+
+func (C *Cartridge) writeCharacter(bank uint, size uint, addr uint, data byte) {
+	if !C.isCharacterRam {
+		return
+	}
+	C.character[(bank*size+addr%size)%uint(len(C.character))] = data
+}
+
+// Boards without PRG-RAM leave 0x6000-0x7FFF unconnected.
+func (C *Cartridge) readProgramRam(addr uint) byte {
+	if len(C.programRam) == 0 {
+		return 0
+	}
+	return C.programRam[(addr-0x6000)%uint(len(C.programRam))]
+}
+
+func (C *Cartridge) writeProgramRam(addr uint, data byte) {
+	if len(C.programRam) == 0 {
+		return
+	}
+	C.programRam[(addr-0x6000)%uint(len(C.programRam))] = data
+}
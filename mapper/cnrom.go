@@ -0,0 +1,48 @@
+package mapper
+
+// CNROM (mapper 3): fixed 16K/32K PRG, switchable 8K CHR bank.
+// Writes to 0x8000-0xFFFF select the bank and conflict with the ROM output.
+type Cnrom struct {
+	*Cartridge
+	characterBank uint
+}
+
+func NewCnrom(cartridge *Cartridge) *Cnrom {
+	cnrom := new(Cnrom)
+	cnrom.Cartridge = cartridge
+	cnrom.PowerOn()
+	return cnrom
+}
+
+func (C *Cnrom) PowerOn() {
+	C.Cartridge.PowerOn()
+	C.characterBank = 0
+}
+
+func (C *Cnrom) ReadByCpu(addr uint) byte {
+	if addr >= 0x8000 {
+		return C.readProgram(0, 0x8000, addr-0x8000)
+	}
+	if addr >= 0x6000 {
+		return C.readProgramRam(addr)
+	}
+	return 0
+}
+
+func (C *Cnrom) WriteByCpu(addr uint, data byte) {
+	if addr >= 0x8000 {
+		C.characterBank = uint(data & C.ReadByCpu(addr))
+		return
+	}
+	if addr >= 0x6000 {
+		C.writeProgramRam(addr, data)
+	}
+}
+
+func (C *Cnrom) ReadByPpu(addr uint) byte {
+	return C.readCharacter(C.characterBank, 0x2000, addr)
+}
+
+func (C *Cnrom) WriteByPpu(addr uint, data byte) {
+	C.writeCharacter(C.characterBank, 0x2000, addr, data)
+}
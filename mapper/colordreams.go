@@ -0,0 +1,46 @@
+package mapper
+
+// Color Dreams (mapper 11): switchable 32K PRG and 8K CHR banks.
+// Writes to 0x8000-0xFFFF: bits 0-1 select the PRG bank, bits 4-7 the CHR bank, with bus conflicts.
+type ColorDreams struct {
+	*Cartridge
+	programBank   uint
+	characterBank uint
+}
+
+func NewColorDreams(cartridge *Cartridge) *ColorDreams {
+	colorDreams := new(ColorDreams)
+	colorDreams.Cartridge = cartridge
+	colorDreams.PowerOn()
+	return colorDreams
+}
+
+func (C *ColorDreams) PowerOn() {
+	C.Cartridge.PowerOn()
+	C.programBank = 0
+	C.characterBank = 0
+}
+
+func (C *ColorDreams) ReadByCpu(addr uint) byte {
+	if addr >= 0x8000 {
+		return C.readProgram(C.programBank, 0x8000, addr)
+	}
+	return 0
+}
+
+func (C *ColorDreams) WriteByCpu(addr uint, data byte) {
+	if addr < 0x8000 {
+		return
+	}
+	data &= C.ReadByCpu(addr)
+	C.programBank = uint(data) & 0x03
+	C.characterBank = uint(data>>4) & 0x0F
+}
+
+func (C *ColorDreams) ReadByPpu(addr uint) byte {
+	return C.readCharacter(C.characterBank, 0x2000, addr)
+}
+
+func (C *ColorDreams) WriteByPpu(addr uint, data byte) {
+	C.writeCharacter(C.characterBank, 0x2000, addr, data)
+}
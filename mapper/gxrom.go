@@ -0,0 +1,46 @@
+package mapper
+
+// GxROM (mapper 66): switchable 32K PRG and 8K CHR banks.
+// Writes to 0x8000-0xFFFF: bits 4-5 select the PRG bank, bits 0-1 the CHR bank, with bus conflicts.
+type Gxrom struct {
+	*Cartridge
+	programBank   uint
+	characterBank uint
+}
+
+func NewGxrom(cartridge *Cartridge) *Gxrom {
+	gxrom := new(Gxrom)
+	gxrom.Cartridge = cartridge
+	gxrom.PowerOn()
+	return gxrom
+}
+
+func (G *Gxrom) PowerOn() {
+	G.Cartridge.PowerOn()
+	G.programBank = 0
+	G.characterBank = 0
+}
+
+func (G *Gxrom) ReadByCpu(addr uint) byte {
+	if addr >= 0x8000 {
+		return G.readProgram(G.programBank, 0x8000, addr)
+	}
+	return 0
+}
+
+func (G *Gxrom) WriteByCpu(addr uint, data byte) {
+	if addr < 0x8000 {
+		return
+	}
+	data &= G.ReadByCpu(addr)
+	G.programBank = uint(data>>4) & 0x03
+	G.characterBank = uint(data) & 0x03
+}
+
+func (G *Gxrom) ReadByPpu(addr uint) byte {
+	return G.readCharacter(G.characterBank, 0x2000, addr)
+}
+
+func (G *Gxrom) WriteByPpu(addr uint, data byte) {
+	G.writeCharacter(G.characterBank, 0x2000, addr, data)
+}
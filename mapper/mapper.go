@@ -0,0 +1,108 @@
+package mapper
+
+import (
+	"github.com/popsul/gones/interrupts"
+	"github.com/popsul/gones/reader"
+)
+
+type Mirroring uint
+
+const (
+	MirroringHorizontal = Mirroring(iota)
+	MirroringVertical
+	MirroringSingleScreenA
+	MirroringSingleScreenB
+	MirroringFourScreen
+)
+
+/*
+	Nametable mapping
+
+	The PPU addresses four 1K nametables at 0x2000-0x2FFF, the console has only 2K of CIRAM.
+	The cartridge maps each nametable to a 1K page:
+
+	| page | memory                                   |
+	+------+------------------------------------------+
+	| 0, 1 | CIRAM                                    |
+	| 2, 3 | cartridge VRAM (four-screen boards)      |
+
+	| nametable        | 0 | 1 | 2 | 3 |
+	+------------------+---+---+---+---+
+	| horizontal       | 0 | 0 | 1 | 1 |
+	| vertical         | 0 | 1 | 0 | 1 |
+	| single-screen A  | 0 | 0 | 0 | 0 |
+	| single-screen B  | 1 | 1 | 1 | 1 |
+	| four-screen      | 0 | 1 | 2 | 3 |
+*/
+
+const NAMETABLE_SIZE = 0x0400
+
+// Page returns the 1K page the nametable (0-3) is mapped to.
+func (M Mirroring) Page(table uint) uint {
+	switch M {
+	case MirroringHorizontal:
+		return table / 2
+	case MirroringVertical:
+		return table % 2
+	case MirroringSingleScreenA:
+		return 0
+	case MirroringSingleScreenB:
+		return 1
+	}
+	return table
+}
+
+// Mapper is the cartridge as seen from both buses.
+// CPU addresses are 0x4020-0xFFFF, PPU addresses are 0x0000-0x1FFF (pattern tables).
+type Mapper interface {
+	ReadByCpu(addr uint) byte
+	WriteByCpu(addr uint, data byte)
+	ReadByPpu(addr uint) byte
+	WriteByPpu(addr uint, data byte)
+	Mirroring() Mirroring
+	// NametablePage is asked on every nametable access, so mappers may remap the nametables mid-frame.
+	// Mappers controlling the nametables themselves (instead of a fixed mirroring) override it.
+	NametablePage(table uint) uint
+	CartridgeVram() []byte
+	// Battery backed PRG-RAM is persisted by the frontend.
+	HasBattery() bool
+	ProgramRam() []byte
+	// PowerOn initializes the registers and clears volatile RAM, battery backed RAM is kept.
+	// Reset is the console reset button, most boards do not see it.
+	PowerOn()
+	Reset()
+}
+
+// PpuClocked is implemented by mappers which need to know the PPU timing,
+// e.g. to filter A12 edges of the pattern table fetches.
+type PpuClocked interface {
+	ClockPpu()
+}
+
+// CpuClocked is implemented by mappers which count CPU cycles (M2).
+type CpuClocked interface {
+	ClockCpu()
+}
+
+func NewMapper(rom *reader.NesRom, interrupts *interrupts.Interrupts) (Mapper, error) {
+	cartridge := NewCartridge(rom, interrupts)
+	switch rom.Mapper {
+	case 0:
+		return NewNrom(cartridge), nil
+	case 1:
+		return NewMmc1(cartridge), nil
+	case 2:
+		return NewUxrom(cartridge), nil
+	case 3:
+		return NewCnrom(cartridge), nil
+	case 4:
+		return NewMmc3(cartridge), nil
+	case 7:
+		return NewAxrom(cartridge), nil
+	case 11:
+		return NewColorDreams(cartridge), nil
+	case 66:
+		return NewGxrom(cartridge), nil
+	}
+	return nil, &reader.UnsupportedMapperError{Mapper: rom.Mapper, SubMapper: rom.SubMapper}
+}
@@ -0,0 +1,181 @@
+package mapper
+
+/*
+MMC1 (mapper 1)
+
+Registers are loaded serially: five writes to 0x8000-0xFFFF shift bit 0 into the load register,
+the fifth write copies it into the register selected by address bits 13-14.
+A write with bit 7 set resets the load register and locks PRG mode 3.
+
+| addr           |  register                                   |
++----------------+---------------------------------------------+
+| 0x8000-0x9FFF  |  Control  CPPMM                             |
+|                |    MM: 0: one-screen A, 1: one-screen B     |
+|                |        2: vertical, 3: horizontal           |
+|                |    PP: 0,1: 32K at 0x8000                   |
+|                |        2: first bank fixed at 0x8000        |
+|                |        3: last bank fixed at 0xC000         |
+|                |    C:  0: 8K CHR, 1: two 4K CHR banks       |
+| 0xA000-0xBFFF  |  CHR bank 0                                 |
+| 0xC000-0xDFFF  |  CHR bank 1 (4K mode only)                  |
+| 0xE000-0xFFFF  |  PRG bank  RPPPP, R: PRG-RAM disable        |
+
+SUROM/SXROM (512K PRG) use bit 4 of CHR bank 0 to select the 256K PRG half,
+SOROM/SXROM use bits 2-3 of CHR bank 0 to select the 8K PRG-RAM page.
+Writes on consecutive CPU cycles (the dummy write of read-modify-write instructions)
+only load the first one.
+*/
+type Mmc1 struct {
+	*Cartridge
+	shiftRegister    byte
+	shiftCount       uint
+	control          byte
+	characterBank0   byte
+	characterBank1   byte
+	programBank      byte
+	isProgramRamLock bool
+	cpuCycle         uint64
+	lastWriteCycle   uint64
+}
+
+func NewMmc1(cartridge *Cartridge) *Mmc1 {
+	mmc1 := new(Mmc1)
+	mmc1.Cartridge = cartridge
+	mmc1.PowerOn()
+	return mmc1
+}
+
+func (M *Mmc1) PowerOn() {
+	M.Cartridge.PowerOn()
+	M.shiftRegister = 0
+	M.shiftCount = 0
+	M.characterBank0 = 0
+	M.characterBank1 = 0
+	M.programBank = 0
+	M.isProgramRamLock = false
+	// NOTE: No write happened yet, the first one is never consecutive.
+	M.lastWriteCycle = ^uint64(0) - 1
+	M.writeControl(0x0C)
+}
+
+func (M *Mmc1) ReadByCpu(addr uint) byte {
+	if addr >= 0x8000 {
+		return M.readProgram(M.programBank16k(addr), 0x4000, addr)
+	}
+	if addr >= 0x6000 {
+		if M.isProgramRamLock {
+			return 0
+		}
+		return M.readProgramRam(M.programRamBank()*PROGRAM_RAM_SIZE + addr)
+	}
+	return 0
+}
+
+func (M *Mmc1) WriteByCpu(addr uint, data byte) {
+	if addr >= 0x8000 {
+		isConsecutive := M.cpuCycle == M.lastWriteCycle+1
+		M.lastWriteCycle = M.cpuCycle
+		if !isConsecutive {
+			M.writeRegister(addr, data)
+		}
+		return
+	}
+	if addr >= 0x6000 && !M.isProgramRamLock {
+		M.writeProgramRam(M.programRamBank()*PROGRAM_RAM_SIZE+addr, data)
+	}
+}
+
+func (M *Mmc1) ReadByPpu(addr uint) byte {
+	return M.readCharacter(M.characterBank4k(addr), 0x1000, addr)
+}
+
+func (M *Mmc1) WriteByPpu(addr uint, data byte) {
+	M.writeCharacter(M.characterBank4k(addr), 0x1000, addr, data)
+}
+
+func (M *Mmc1) ClockCpu() {
+	M.cpuCycle++
+}
+
+func (M *Mmc1) writeRegister(addr uint, data byte) {
+	if data&0x80 > 0 {
+		M.shiftRegister = 0
+		M.shiftCount = 0
+		M.writeControl(M.control | 0x0C)
+		return
+	}
+	M.shiftRegister |= (data & 0x01) << M.shiftCount
+	M.shiftCount++
+	if M.shiftCount < 5 {
+		return
+	}
+
+	value := M.shiftRegister
+	M.shiftRegister = 0
+	M.shiftCount = 0
+	switch (addr >> 13) & 0x03 {
+	case 0:
+		M.writeControl(value)
+	case 1:
+		M.characterBank0 = value
+	case 2:
+		M.characterBank1 = value
+	case 3:
+		M.programBank = value & 0x0F
+		M.isProgramRamLock = value&0x10 > 0
+	}
+}
+
+func (M *Mmc1) writeControl(value byte) {
+	M.control = value
+	switch value & 0x03 {
+	case 0:
+		M.setMirroring(MirroringSingleScreenA)
+	case 1:
+		M.setMirroring(MirroringSingleScreenB)
+	case 2:
+		M.setMirroring(MirroringVertical)
+	case 3:
+		M.setMirroring(MirroringHorizontal)
+	}
+}
+
+// 256K outer bank used by SUROM/SXROM boards.
+func (M *Mmc1) programOuterBank() uint {
+	if len(M.program) > 0x40000 && M.characterBank0&0x10 > 0 {
+		return 16
+	}
+	return 0
+}
+
+func (M *Mmc1) programBank16k(addr uint) uint {
+	outer := M.programOuterBank()
+	bank := uint(M.programBank)
+	switch (M.control >> 2) & 0x03 {
+	case 0, 1:
+		return outer + (bank &^ 0x01) + (addr-0x8000)/0x4000
+	case 2:
+		if addr < 0xC000 {
+			return outer
+		}
+		return outer + bank
+	}
+	if addr < 0xC000 {
+		return outer + bank
+	}
+	return outer + 15
+}
+
+func (M *Mmc1) programRamBank() uint {
+	return uint(M.characterBank0>>2) & 0x03
+}
+
+func (M *Mmc1) characterBank4k(addr uint) uint {
+	if M.control&0x10 == 0 {
+		return uint(M.characterBank0&^0x01) + addr/0x1000
+	}
+	if addr < 0x1000 {
+		return uint(M.characterBank0)
+	}
+	return uint(M.characterBank1)
+}
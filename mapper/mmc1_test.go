@@ -0,0 +1,50 @@
+package mapper
+
+import (
+	"testing"
+
+	"github.com/popsul/gones/reader"
+)
+
+// newMmc1 returns an MMC1 with 16 PRG banks, the first byte of each bank is its number.
+func newMmc1() *Mmc1 {
+	rom := new(reader.NesRom)
+	rom.Mapper = 1
+	rom.Program = make([]byte, 16*reader.PROGRAM_ROM_SIZE)
+	for bank := 0; bank < 16; bank++ {
+		rom.Program[bank*reader.PROGRAM_ROM_SIZE] = byte(bank)
+	}
+	rom.CharacterRamSize = reader.CHARACTER_ROM_SIZE
+	return NewMmc1(NewCartridge(rom, nil))
+}
+
+func TestMmc1FirstWrite(t *testing.T) {
+	mmc1 := newMmc1()
+	// The first write is on the first CPU cycle, no write happened before it.
+	mmc1.ClockCpu()
+	for i := 0; i < 5; i++ {
+		if i > 0 {
+			mmc1.ClockCpu()
+			mmc1.ClockCpu()
+		}
+		mmc1.WriteByCpu(0xE000, 0x05>>uint(i)&0x01)
+	}
+	if bank := mmc1.ReadByCpu(0x8000); bank != 0x05 {
+		t.Errorf("PRG bank %d, want 5", bank)
+	}
+}
+
+func TestMmc1ConsecutiveWrites(t *testing.T) {
+	mmc1 := newMmc1()
+	// Only the first write of each pair loads a bit, like the dummy write of INC/ASL.
+	for i := 0; i < 5; i++ {
+		mmc1.ClockCpu()
+		mmc1.ClockCpu()
+		mmc1.WriteByCpu(0xE000, 0x03>>uint(i)&0x01)
+		mmc1.ClockCpu()
+		mmc1.WriteByCpu(0xE000, 0x01)
+	}
+	if bank := mmc1.ReadByCpu(0x8000); bank != 0x03 {
+		t.Errorf("PRG bank %d, want 3", bank)
+	}
+}
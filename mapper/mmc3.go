@@ -0,0 +1,176 @@
+package mapper
+
+import "github.com/popsul/gones/interrupts"
+
+/*
+MMC3 (mapper 4)
+
+| addr           |  even                       |  odd                        |
++----------------+-----------------------------+-----------------------------+
+| 0x8000-0x9FFF  |  Bank select  CP...RRR      |  Bank data for R0-R7        |
+| 0xA000-0xBFFF  |  Mirroring 0: vert, 1: hor  |  PRG-RAM protect  EW......  |
+| 0xC000-0xDFFF  |  IRQ latch                  |  IRQ reload                 |
+| 0xE000-0xFFFF  |  IRQ disable/acknowledge    |  IRQ enable                 |
+
+	C: CHR A12 inversion, P: PRG mode, RRR: target bank register.
+	R0, R1: 2K CHR banks, R2-R5: 1K CHR banks, R6, R7: 8K PRG banks.
+
+The scanline counter is clocked by rising edges of PPU address line A12. The board filters
+out short pulses (A12 has to stay low for a few M2 cycles), which is emulated by ignoring
+rises that come within a few PPU dots after the previous A12 high access.
+*/
+type Mmc3 struct {
+	*Cartridge
+	bankSelect           byte
+	banks                [8]uint
+	isProgramRamEnable   bool
+	isProgramRamWritable bool
+	irqLatch             byte
+	irqCounter           byte
+	isIrqReload          bool
+	isIrqEnable          bool
+	ppuCycle             uint
+	lastA12HighCycle     uint
+}
+
+// ~3 M2 cycles in PPU dots.
+const MMC3_A12_FILTER = 10
+
+func NewMmc3(cartridge *Cartridge) *Mmc3 {
+	mmc3 := new(Mmc3)
+	mmc3.Cartridge = cartridge
+	mmc3.PowerOn()
+	return mmc3
+}
+
+func (M *Mmc3) PowerOn() {
+	M.Cartridge.PowerOn()
+	M.bankSelect = 0
+	M.banks = [8]uint{}
+	M.isProgramRamEnable = true
+	M.isProgramRamWritable = true
+	M.irqLatch = 0
+	M.irqCounter = 0
+	M.isIrqReload = false
+	M.isIrqEnable = false
+	M.interrupts.ReleaseIrq(interrupts.IrqMapper)
+}
+
+func (M *Mmc3) ReadByCpu(addr uint) byte {
+	if addr >= 0x8000 {
+		return M.readProgram(M.programBank8k(addr), 0x2000, addr)
+	}
+	if addr >= 0x6000 && M.isProgramRamEnable {
+		return M.readProgramRam(addr)
+	}
+	return 0
+}
+
+func (M *Mmc3) WriteByCpu(addr uint, data byte) {
+	if addr >= 0x8000 {
+		M.writeRegister(addr, data)
+		return
+	}
+	if addr >= 0x6000 && M.isProgramRamEnable && M.isProgramRamWritable {
+		M.writeProgramRam(addr, data)
+	}
+}
+
+func (M *Mmc3) ReadByPpu(addr uint) byte {
+	M.watchA12(addr)
+	return M.readCharacter(M.characterBank1k(addr), 0x0400, addr)
+}
+
+func (M *Mmc3) WriteByPpu(addr uint, data byte) {
+	M.watchA12(addr)
+	M.writeCharacter(M.characterBank1k(addr), 0x0400, addr, data)
+}
+
+func (M *Mmc3) ClockPpu() {
+	M.ppuCycle++
+}
+
+func (M *Mmc3) writeRegister(addr uint, data byte) {
+	isEven := addr&0x01 == 0
+	switch {
+	case addr < 0xA000 && isEven:
+		M.bankSelect = data
+	case addr < 0xA000:
+		M.banks[M.bankSelect&0x07] = uint(data)
+	case addr < 0xC000 && isEven:
+		if data&0x01 > 0 {
+			M.setMirroring(MirroringHorizontal)
+		} else {
+			M.setMirroring(MirroringVertical)
+		}
+	case addr < 0xC000:
+		M.isProgramRamEnable = data&0x80 > 0
+		M.isProgramRamWritable = data&0x40 == 0
+	case addr < 0xE000 && isEven:
+		M.irqLatch = data
+	case addr < 0xE000:
+		M.irqCounter = 0
+		M.isIrqReload = true
+	case isEven:
+		M.isIrqEnable = false
+		M.interrupts.ReleaseIrq(interrupts.IrqMapper)
+	default:
+		M.isIrqEnable = true
+	}
+}
+
+func (M *Mmc3) programBank8k(addr uint) uint {
+	last := uint(len(M.program))/0x2000 - 1
+	isSwapped := M.bankSelect&0x40 > 0
+	switch (addr - 0x8000) / 0x2000 {
+	case 0:
+		if isSwapped {
+			return last - 1
+		}
+		return M.banks[6]
+	case 1:
+		return M.banks[7]
+	case 2:
+		if isSwapped {
+			return M.banks[6]
+		}
+		return last - 1
+	}
+	return last
+}
+
+func (M *Mmc3) characterBank1k(addr uint) uint {
+	slot := addr / 0x0400
+	if M.bankSelect&0x80 > 0 {
+		slot ^= 0x04
+	}
+	switch slot {
+	case 0, 1:
+		return M.banks[0]&^0x01 + slot
+	case 2, 3:
+		return M.banks[1]&^0x01 + slot - 2
+	}
+	return M.banks[slot-2]
+}
+
+func (M *Mmc3) watchA12(addr uint) {
+	if addr&0x1000 == 0 {
+		return
+	}
+	if M.ppuCycle-M.lastA12HighCycle > MMC3_A12_FILTER {
+		M.clockScanlineCounter()
+	}
+	M.lastA12HighCycle = M.ppuCycle
+}
+
+func (M *Mmc3) clockScanlineCounter() {
+	if M.irqCounter == 0 || M.isIrqReload {
+		M.irqCounter = M.irqLatch
+		M.isIrqReload = false
+	} else {
+		M.irqCounter--
+	}
+	if M.irqCounter == 0 && M.isIrqEnable {
+		M.interrupts.AssertIrq(interrupts.IrqMapper)
+	}
+}
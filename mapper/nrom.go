@@ -0,0 +1,36 @@
+package mapper
+
+// NROM (mapper 0): 16K or 32K PRG ROM, 16K is mirrored into 0xC000-0xFFFF. No bank switching.
+type Nrom struct {
+	*Cartridge
+}
+
+func NewNrom(cartridge *Cartridge) *Nrom {
+	nrom := new(Nrom)
+	nrom.Cartridge = cartridge
+	return nrom
+}
+
+func (N *Nrom) ReadByCpu(addr uint) byte {
+	if addr >= 0x8000 {
+		return N.readProgram(0, 0x8000, addr-0x8000)
+	}
+	if addr >= 0x6000 {
+		return N.readProgramRam(addr)
+	}
+	return 0
+}
+
+func (N *Nrom) WriteByCpu(addr uint, data byte) {
+	if addr >= 0x6000 && addr < 0x8000 {
+		N.writeProgramRam(addr, data)
+	}
+}
+
+func (N *Nrom) ReadByPpu(addr uint) byte {
+	return N.readCharacter(0, 0x2000, addr)
+}
+
+func (N *Nrom) WriteByPpu(addr uint, data byte) {
+	N.writeCharacter(0, 0x2000, addr, data)
+}
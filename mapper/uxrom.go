@@ -0,0 +1,51 @@
+package mapper
+
+// UxROM (mapper 2): switchable 16K PRG bank at 0x8000, last bank fixed at 0xC000, 8K CHR-RAM.
+// Writes to 0x8000-0xFFFF select the bank and conflict with the ROM output.
+type Uxrom struct {
+	*Cartridge
+	programBank uint
+}
+
+func NewUxrom(cartridge *Cartridge) *Uxrom {
+	uxrom := new(Uxrom)
+	uxrom.Cartridge = cartridge
+	uxrom.PowerOn()
+	return uxrom
+}
+
+func (U *Uxrom) PowerOn() {
+	U.Cartridge.PowerOn()
+	U.programBank = 0
+}
+
+func (U *Uxrom) ReadByCpu(addr uint) byte {
+	if addr >= 0xC000 {
+		return U.readProgram(uint(len(U.program))/0x4000-1, 0x4000, addr)
+	}
+	if addr >= 0x8000 {
+		return U.readProgram(U.programBank, 0x4000, addr)
+	}
+	if addr >= 0x6000 {
+		return U.readProgramRam(addr)
+	}
+	return 0
+}
+
+func (U *Uxrom) WriteByCpu(addr uint, data byte) {
+	if addr >= 0x8000 {
+		U.programBank = uint(data & U.ReadByCpu(addr))
+		return
+	}
+	if addr >= 0x6000 {
+		U.writeProgramRam(addr, data)
+	}
+}
+
+func (U *Uxrom) ReadByPpu(addr uint) byte {
+	return U.readCharacter(0, 0x2000, addr)
+}
+
+func (U *Uxrom) WriteByPpu(addr uint, data byte) {
+	U.writeCharacter(0, 0x2000, addr, data)
+}
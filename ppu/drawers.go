@@ -16,6 +16,9 @@ const height = 224
 
 type Drawer interface {
 	Draw(buffer []uint8)
+	IsQuit() bool
+	// IsResetRequested reports the reset hotkey once, the request is cleared by the call.
+	IsResetRequested() bool
 }
 
 type PngDrawer struct {
@@ -29,6 +32,9 @@ type SDLDrawer struct {
 	frame      int64
 	keypad     *bus.Keypad
 	scale      int
+	isQuit     bool
+	// Soft reset hotkey (R)
+	isResetRequested bool
 }
 
 func NewPngDrawer() *PngDrawer {
@@ -67,6 +73,14 @@ func (D *PngDrawer) Draw(buffer []uint8) {
 	}
 }
 
+func (D *PngDrawer) IsQuit() bool {
+	return false
+}
+
+func (D *PngDrawer) IsResetRequested() bool {
+	return false
+}
+
 func NewSDLDrawer(keypad *bus.Keypad) *SDLDrawer {
 	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
 		panic(err)
@@ -106,6 +120,8 @@ func NewSDLDrawer(keypad *bus.Keypad) *SDLDrawer {
 		0,
 		keypad,
 		2,
+		false,
+		false,
 	}
 }
 
@@ -134,12 +150,15 @@ func (D *SDLDrawer) Draw(buffer []byte) {
 
 	for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
 		if event.GetType() == sdl.QUIT {
-			os.Exit(0)
+			D.isQuit = true
 		}
 		switch event.(type) {
 		case *sdl.KeyboardEvent:
 			ev := event.(*sdl.KeyboardEvent)
 			if ev.Type == sdl.KEYDOWN {
+				if ev.Keysym.Sym == sdl.K_r && ev.Repeat == 0 {
+					D.isResetRequested = true
+				}
 				//fmt.Printf("keydown 0x%02x\n", D.matchKey(ev.Keysym.Sym))
 				D.keypad.KeyDown(D.matchKey(ev.Keysym.Sym))
 			} else if ev.Type == sdl.KEYUP {
@@ -165,6 +184,16 @@ func (D *SDLDrawer) Draw(buffer []byte) {
 	}
 }
 
+func (D *SDLDrawer) IsQuit() bool {
+	return D.isQuit
+}
+
+func (D *SDLDrawer) IsResetRequested() bool {
+	isResetRequested := D.isResetRequested
+	D.isResetRequested = false
+	return isResetRequested
+}
+
 func (D *SDLDrawer) scaleUp() {
 	if D.scale > 5 {
 		return
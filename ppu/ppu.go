@@ -6,8 +6,6 @@ import (
 	"github.com/popsul/gones/interrupts"
 )
 
-const SPRITES_NUMBER = 0x100
-
 type Ppu struct {
 	// PPU power up state
 	// see. https://wiki.nesdev.com/w/index.php/PPU_power_up_state
@@ -60,82 +58,137 @@ type Ppu struct {
 		|  1   | Background mask   render left end           |
 		|  0   | Display type      0: color, 1: mono         |
 	*/
+	/*
+		  Internal registers (loopy)
+		| register | description                                             |
+		+----------+---------------------------------------------------------+
+		| v        | current VRAM address, 15 bits  yyy NN YYYYY XXXXX       |
+		| t        | temporary VRAM address, the top left of the screen      |
+		| x        | fine X scroll, 3 bits                                   |
+		| w        | first/second write toggle of 0x2005 and 0x2006          |
+
+		  yyy: fine Y, NN: name table, YYYYY: coarse Y, XXXXX: coarse X
+		  see. https://wiki.nesdev.com/w/index.php/PPU_scrolling
+	*/
 	/** @var int[] */
 	registers []byte
 	/** @var int */
 	cycle uint
 	/** @var int */
 	line uint
-	/** @var bool */
-	isValidVramAddr bool
-	/** @var bool */
-	isLowerVramAddr bool
+	// Rendering skips a dot of the pre-render line on odd frames.
+	isOddFrame bool
 	/** @var int */
 	spriteRamAddr uint
-	/** @var int */
-	vramAddr uint
-	/** @var \Nes\Bus\Ram */
-	vram bus.Ram
+	vramAddr      uint
+	tempVramAddr  uint
+	fineX         uint
+	isSecondWrite bool
 	/** @var int */
 	vramReadBuf byte
 	/** @var \Nes\Bus\Ram */
 	spriteRam bus.Ram
 	/** @var \Nes\Bus\PpuBus */
 	bus *bus.PpuBus
-	/** @var \Nes\Ppu\Tile[] */
-	background []Tile
-	/** @var \Nes\Ppu\SpriteWithAttribute[] */
-	sprites []SpriteWithAttribute
 	/** @var \Nes\Ppu\Palette */
 	palette Palette
 	/** @var \Nes\Cpu\Interrupts */
 	interrupts *interrupts.Interrupts
-	/** @var bool */
-	isHorizontalScroll bool
-	/** @var int */
-	scrollX uint
-	/** @var int */
-	scrollY uint
-	/** @var bool */
-	isHorizontalMirror bool
+	// Background fetch latches and shift registers, see. renderDot
+	nameTableLatch       uint
+	attributeLatch       uint
+	patternLowLatch      uint
+	patternHighLatch     uint
+	patternLowShifter    uint
+	patternHighShifter   uint
+	attributeLowShifter  uint
+	attributeHighShifter uint
+	// Sprites of the current line, fetched during dots 257-320 of the previous line.
+	// Only the first SPRITE_SLOTS are used unless the limit is disabled.
+	spriteSlots [SPRITE_COUNT]spriteSlot
+	spriteCount uint
+	// Draws every sprite of a line, reduces the flicker of games cycling sprites over the limit.
+	isSpriteLimitDisabled bool
+	// Palette indices of the frame being rendered.
+	frame []byte
+	// Writes to 0x2000, 0x2001, 0x2005 and 0x2006 are ignored until the first pre-render line
+	isWarmingUp bool
+}
+
+type spriteSlot struct {
+	x            uint
+	attribute    uint
+	tile         uint
+	row          uint
+	patternLow   byte
+	patternHigh  byte
+	isSpriteZero bool
 }
 
 type RenderingData struct {
-	palette    []byte
-	background []Tile
-	sprites    []SpriteWithAttribute
+	// Palette indices (0x00-0x3F), SCREEN_WIDTH x SCREEN_HEIGHT
+	frame []byte
 }
 
-func NewPpu(ppuBus *bus.PpuBus, interrupts *interrupts.Interrupts, isHorizontalMirror bool) *Ppu {
+const (
+	SCREEN_WIDTH  = 256
+	SCREEN_HEIGHT = 240
+	SPRITE_SLOTS  = 8
+	SPRITE_COUNT  = 64
+)
+
+func NewPpu(ppuBus *bus.PpuBus, interrupts *interrupts.Interrupts) *Ppu {
 	ppu := new(Ppu)
 	ppu.registers = make([]byte, 8)
 	ppu.cycle = 0
 	ppu.line = 0
-	ppu.isValidVramAddr = false
-	ppu.isLowerVramAddr = false
-	ppu.isHorizontalScroll = true
 	ppu.vramAddr = 0x0000
-	ppu.vram = *bus.NewRam(0x2000)
 	ppu.vramReadBuf = 0
 	ppu.spriteRam = *bus.NewRam(0x100)
 	ppu.spriteRamAddr = 0
-	ppu.background = []Tile{}
-	ppu.sprites = make([]SpriteWithAttribute, SPRITES_NUMBER)
 	ppu.bus = ppuBus
 	ppu.interrupts = interrupts
-	ppu.isHorizontalMirror = isHorizontalMirror
-	ppu.scrollX = 0
-	ppu.scrollY = 0
 	ppu.palette = *NewPalette()
+	ppu.frame = make([]byte, SCREEN_WIDTH*SCREEN_HEIGHT)
+	ppu.isWarmingUp = true
 
 	return ppu
 }
 
-func NewRenderingData(palette []byte, background []Tile, sprites []SpriteWithAttribute) *RenderingData {
+// PowerOn clears the registers and starts the warm-up, like the power switch.
+// see. https://wiki.nesdev.com/w/index.php/PPU_power_up_state
+func (P *Ppu) PowerOn() {
+	for i := range P.registers {
+		P.registers[i] = 0
+	}
+	P.vramAddr = 0x0000
+	P.tempVramAddr = 0x0000
+	P.spriteRamAddr = 0
+	P.isOddFrame = false
+	P.reset()
+}
+
+// Reset is the reset button: PPUCTRL and PPUMASK are cleared, PPUSTATUS and the VRAM address are kept.
+func (P *Ppu) Reset() {
+	P.registers[0x00] = 0
+	P.registers[0x01] = 0
+	P.reset()
+}
+
+func (P *Ppu) reset() {
+	P.cycle = 0
+	P.line = 0
+	P.isSecondWrite = false
+	P.tempVramAddr = 0x0000
+	P.fineX = 0
+	P.vramReadBuf = 0
+	P.isWarmingUp = true
+	P.updateNmi()
+}
+
+func NewRenderingData(frame []byte) *RenderingData {
 	return &RenderingData{
-		palette,
-		background,
-		sprites,
+		frame,
 	}
 }
 
@@ -153,19 +206,29 @@ func (P *Ppu) vramOffset() uint {
 	return 1
 }
 
+// INFO: While rendering, accessing 0x2007 increments coarse X and Y like the fetches instead.
+func (P *Ppu) incrementVramAddr() {
+	if P.isRendering() && (P.line < 240 || P.line == 261) {
+		P.incrementX()
+		P.incrementY()
+		return
+	}
+	P.vramAddr = (P.vramAddr + P.vramOffset()) & 0x7FFF
+}
+
 func (P *Ppu) ReadVram() byte {
 	buf := P.vramReadBuf
-	if P.vramAddr >= 0x2000 {
-		addr := P.calcVramAddr()
-		P.vramAddr += P.vramOffset()
-		if addr >= 0x3F00 {
-			return P.vram.Read(addr)
-		}
-		P.vramReadBuf = P.vram.Read(addr)
+	addr := P.vramAddr & 0x3FFF
+	if addr >= 0x3F00 {
+		// INFO: Palette reads are not buffered, the buffer gets the name table "under" the palette.
+		buf = P.palette.ram.Read(P.palette.GetPaletteAddr(addr))
+		P.vramReadBuf = P.bus.ReadNametable(addr & 0x0FFF)
+	} else if addr >= 0x2000 {
+		P.vramReadBuf = P.bus.ReadNametable(addr & 0x0FFF)
 	} else {
-		P.vramReadBuf = P.ReadCharacterRAM(P.vramAddr)
-		P.vramAddr += P.vramOffset()
+		P.vramReadBuf = P.ReadCharacterRAM(addr)
 	}
+	P.incrementVramAddr()
 	return buf
 }
 
@@ -181,10 +244,10 @@ func (P *Ppu) Read(addr uint) byte {
 		|      | bit4 VRAM write flag [0: success, 1: fail]  |
 	*/
 	if addr == 0x0002 {
-		P.isHorizontalScroll = true
+		P.isSecondWrite = false
 		data = P.registers[0x02]
 		P.clearVblank()
-		// P.clearSpriteHit();
+		P.updateNmi()
 	}
 	// Write OAM data here. Writes will increment OAMADDR after the write
 	// reads during vertical or forced blanking return the value from OAM at that address but do not increment.
@@ -199,6 +262,13 @@ func (P *Ppu) Read(addr uint) byte {
 }
 
 func (P *Ppu) Write(addr uint, data byte) {
+	if P.isWarmingUp && (addr == 0x0000 || addr == 0x0001 || addr == 0x0005 || addr == 0x0006) {
+		return
+	}
+	if addr == 0x0000 {
+		// t: ...GH.. ........ <- d: ......GH
+		P.tempVramAddr = (P.tempVramAddr &^ 0x0C00) | (uint(data)&0x03)<<10
+	}
 	if addr == 0x0003 {
 		P.writeSpriteRamAddr(data)
 	}
@@ -215,6 +285,9 @@ func (P *Ppu) Write(addr uint, data byte) {
 		P.writeVramData(data)
 	}
 	P.registers[addr] = data
+	if addr == 0x0000 {
+		P.updateNmi()
+	}
 }
 
 func (P *Ppu) writeSpriteRamAddr(data byte) {
@@ -223,63 +296,44 @@ func (P *Ppu) writeSpriteRamAddr(data byte) {
 
 func (P *Ppu) writeSpriteRamData(data byte) {
 	P.spriteRam.Write(P.spriteRamAddr, data)
-	P.spriteRamAddr += 1
+	P.spriteRamAddr = (P.spriteRamAddr + 1) & 0xFF
 }
 
 func (P *Ppu) writeScrollData(data byte) {
-	if P.isHorizontalScroll {
-		P.isHorizontalScroll = false
-		P.scrollX = uint(data) & 0xFF
+	if !P.isSecondWrite {
+		// t: ....... ...ABCDE <- d: ABCDE...
+		// x:              FGH <- d: .....FGH
+		P.tempVramAddr = (P.tempVramAddr &^ 0x001F) | uint(data)>>3
+		P.fineX = uint(data) & 0x07
 	} else {
-		P.isHorizontalScroll = true
-		P.scrollY = uint(data) & 0xFF
+		// t: FGH..AB CDE..... <- d: ABCDEFGH
+		P.tempVramAddr = (P.tempVramAddr &^ 0x73E0) | (uint(data)&0x07)<<12 | (uint(data)&0xF8)<<2
 	}
+	P.isSecondWrite = !P.isSecondWrite
 }
 
 func (P *Ppu) writeVramAddr(data byte) {
-	if P.isLowerVramAddr {
-		P.vramAddr += uint(data)
-		P.isLowerVramAddr = false
-		P.isValidVramAddr = true
-	} else {
-		P.vramAddr = uint(data) << 8
-		P.isLowerVramAddr = true
-		P.isValidVramAddr = false
-	}
-}
-
-func (P *Ppu) calcVramAddr() uint {
-	if P.vramAddr >= 0x3000 && P.vramAddr < 0x3f00 {
-		P.vramAddr -= 0x3000
-		return P.vramAddr
+	if !P.isSecondWrite {
+		// t: .CDEFGH ........ <- d: ..CDEFGH, bit 14 is cleared
+		P.tempVramAddr = (P.tempVramAddr & 0x00FF) | (uint(data)&0x3F)<<8
 	} else {
-		return P.vramAddr - 0x2000
+		// t: ....... ABCDEFGH <- d: ABCDEFGH, then v = t
+		P.tempVramAddr = (P.tempVramAddr & 0xFF00) | uint(data)
+		P.vramAddr = P.tempVramAddr
 	}
+	P.isSecondWrite = !P.isSecondWrite
 }
 
 func (P *Ppu) writeVramData(data byte) {
-	if P.vramAddr >= 0x2000 {
-		if P.vramAddr >= 0x3f00 && P.vramAddr < 0x4000 {
-			P.palette.Write(P.vramAddr-0x3f00, data)
-		} else {
-			P.writeVram(P.calcVramAddr(), data)
-		}
+	addr := P.vramAddr & 0x3FFF
+	if addr >= 0x3F00 {
+		P.palette.Write(addr-0x3F00, data)
+	} else if addr >= 0x2000 {
+		P.bus.WriteNametable(addr&0x0FFF, data)
 	} else {
-		P.WriteCharacterRAM(P.vramAddr, data)
+		P.WriteCharacterRAM(addr, data)
 	}
-	P.vramAddr += P.vramOffset()
-}
-
-func (P *Ppu) writeVram(addr uint, data byte) {
-	P.vram.Write(addr, data)
-}
-
-func (P *Ppu) nameTableId() uint {
-	return uint(P.registers[0x00] & 0x03)
-}
-
-func (P *Ppu) getPalette() []byte {
-	return P.palette.Read()
+	P.incrementVramAddr()
 }
 
 func (P *Ppu) clearSpriteHit() {
@@ -290,10 +344,12 @@ func (P *Ppu) setSpriteHit() {
 	P.registers[0x02] |= 0x40
 }
 
-func (P *Ppu) hasSpriteHit() bool {
-	//P.spriteRam.Dump()
-	y := uint(P.spriteRam.Read(0))
-	return (y == P.line) && P.isBackgroundEnable() && P.isSpriteEnable()
+func (P *Ppu) setSpriteOverflow() {
+	P.registers[0x02] |= 0x20
+}
+
+func (P *Ppu) clearSpriteOverflow() {
+	P.registers[0x02] &= 0xdf
 }
 
 func (P *Ppu) hasVblankIrqEnabled() bool {
@@ -308,28 +364,8 @@ func (P *Ppu) isSpriteEnable() bool {
 	return P.registers[0x01]&0x10 > 0
 }
 
-func (P *Ppu) scrollTileX() uint {
-	/*
-	  Name table id and address
-	  +------------+------------+
-	  |            |            |
-	  |  0(0x2000) |  1(0x2400) |
-	  |            |            |
-	  +------------+------------+
-	  |            |            |
-	  |  2(0x2800) |  3(0x2C00) |
-	  |            |            |
-	  +------------+------------+
-	*/
-	return (P.scrollX + ((P.nameTableId() % 2) * 256)) / 8
-}
-
-func (P *Ppu) scrollTileY() uint {
-	return (P.scrollY + ((P.nameTableId() / 2) * 240)) / 8
-}
-
-func (P *Ppu) tileY() uint {
-	return (P.line / 8) + P.scrollTileY()
+func (P *Ppu) isRendering() bool {
+	return P.isBackgroundEnable() || P.isSpriteEnable()
 }
 
 func (P *Ppu) backgroundTableOffset() uint {
@@ -339,6 +375,20 @@ func (P *Ppu) backgroundTableOffset() uint {
 	return 0x0000
 }
 
+func (P *Ppu) spriteTableOffset() uint {
+	return I2ix(uint(P.registers[0])&0x08, 0x1000, 0x0000)
+}
+
+// The NMI output is the vblank flag AND the NMI enable bit, the CPU triggers on its rising edge.
+// So enabling NMI during vblank triggers another NMI.
+func (P *Ppu) updateNmi() {
+	if P.isVblank() && P.hasVblankIrqEnabled() {
+		P.interrupts.AssertNmi()
+	} else {
+		P.interrupts.ReleaseNmi()
+	}
+}
+
 func (P *Ppu) setVblank() {
 	P.registers[0x02] |= 0x80
 }
@@ -351,153 +401,390 @@ func (P *Ppu) clearVblank() {
 	P.registers[0x02] &= 0x7F
 }
 
-func (P *Ppu) getBlockId(tileX uint, tileY uint) uint {
-	return ^^((tileX % 4) / 2) + (^^((tileY % 4) / 2))*2
+func (P *Ppu) TransferSprite(index uint, data byte) {
+	// The DMA transfer will begin at the current OAM write address.
+	// It is common practice to initialize it to 0 with a write to PPU 0x2003 before the DMA transfer.
+	// Different starting addresses can be used for a simple OAM cycling technique
+	// to alleviate sprite priority conflicts by flickering. If using this technique
+	// after the DMA OAMADDR should be set to 0 before the end of vblank to prevent potential OAM corruption
+	// (See: Errata).
+	// However, due to OAMADDR writes also having a "corruption" effect[5] this technique is not recommended.
+	addr := index + P.spriteRamAddr
+	P.spriteRam.Write(addr%0x100, data)
+}
+
+// Position returns the current scanline and dot.
+func (P *Ppu) Position() (uint, uint) {
+	return P.line, P.cycle
+}
+
+func (P *Ppu) Run(cycle uint) *RenderingData {
+	var renderingData *RenderingData = nil
+	for ; cycle > 0; cycle-- {
+		if data := P.step(); data != nil {
+			renderingData = data
+		}
+	}
+	return renderingData
+}
+
+/*
+	Frame timing (NTSC), 341 dots per line
+
+	| line    | description                                                      |
+	+---------+------------------------------------------------------------------+
+	| 0-239   | visible, a pixel per dot 1-256                                   |
+	| 240     | post-render, idle                                                |
+	| 241-260 | vblank, the flag is set at dot 1 of line 241                     |
+	| 261     | pre-render, fetches like a visible line, the flags are cleared   |
+	|         | at dot 1 and v gets the vertical bits of t during dots 280-304   |
+*/
+
+func (P *Ppu) step() *RenderingData {
+	var renderingData *RenderingData = nil
+	isRenderLine := P.line < 240 || P.line == 261
+	if P.isRendering() && isRenderLine {
+		P.renderDot()
+	}
+	if P.line < 240 && P.cycle >= 1 && P.cycle <= 256 {
+		P.putPixel(P.cycle-1, P.line)
+	}
+	if P.cycle == 1 {
+		if P.line == 241 {
+			P.setVblank()
+			P.updateNmi()
+			renderingData = NewRenderingData(append([]byte(nil), P.frame...))
+		}
+		if P.line == 261 {
+			P.clearVblank()
+			P.clearSpriteHit()
+			P.clearSpriteOverflow()
+			P.updateNmi()
+			P.isWarmingUp = false
+		}
+	}
+	P.bus.Tick()
+
+	P.cycle++
+	if P.line == 261 && P.cycle == 340 && P.isOddFrame && P.isRendering() {
+		P.cycle = 341
+	}
+	if P.cycle > 340 {
+		P.cycle = 0
+		P.line++
+		if P.line > 261 {
+			P.line = 0
+			P.isOddFrame = !P.isOddFrame
+		}
+	}
+	return renderingData
+}
+
+// INFO: Each fetch takes 2 dots (name table, attribute, pattern low, pattern high),
+// the data is loaded into the shift registers every 8 dots.
+// see. https://wiki.nesdev.com/w/index.php/PPU_rendering
+func (P *Ppu) renderDot() {
+	dot := P.cycle
+	if dot >= 2 && dot <= 257 || dot >= 322 && dot <= 337 {
+		P.shiftBackground()
+	}
+	if dot >= 1 && dot <= 256 || dot >= 321 && dot <= 336 {
+		switch dot % 8 {
+		case 1:
+			P.loadBackgroundShifters()
+			P.nameTableLatch = uint(P.bus.ReadNametable(P.vramAddr & 0x0FFF))
+		case 3:
+			P.attributeLatch = P.fetchAttribute()
+		case 5:
+			P.patternLowLatch = uint(P.ReadCharacterRAM(P.backgroundPatternAddr()))
+		case 7:
+			P.patternHighLatch = uint(P.ReadCharacterRAM(P.backgroundPatternAddr() + 8))
+		case 0:
+			P.incrementX()
+		}
+	}
+	if dot == 256 {
+		P.incrementY()
+	}
+	if dot == 257 {
+		P.loadBackgroundShifters()
+		P.copyX()
+		P.evaluateSprites()
+	}
+	if dot >= 257 && dot <= 320 {
+		P.fetchSprites()
+	}
+	if dot == 337 || dot == 339 {
+		// Unused name table fetches
+		P.bus.ReadNametable(P.vramAddr & 0x0FFF)
+	}
+	if P.line == 261 && dot >= 280 && dot <= 304 {
+		P.copyY()
+	}
+}
+
+func (P *Ppu) fetchAttribute() uint {
+	v := P.vramAddr
+	addr := 0x03C0 | (v & 0x0C00) | ((v >> 4) & 0x38) | ((v >> 2) & 0x07)
+	attribute := uint(P.bus.ReadNametable(addr))
+	// Each attribute byte covers 4x4 tiles, 2 bits per 2x2 tiles.
+	shift := ((v >> 4) & 0x04) | (v & 0x02)
+	return (attribute >> shift) & 0x03
+}
+
+func (P *Ppu) backgroundPatternAddr() uint {
+	fineY := (P.vramAddr >> 12) & 0x07
+	return P.backgroundTableOffset() + P.nameTableLatch*16 + fineY
 }
 
-func (P *Ppu) getAttribute(tileX uint, tileY uint, offset uint) uint {
-	addr := ^^(tileX / 4) + (^^(tileY / 4) * 8) + 0x03C0 + offset
-	return uint(P.vram.Read(P.mirrorDownSpriteAddr(addr)))
+func (P *Ppu) loadBackgroundShifters() {
+	P.patternLowShifter = (P.patternLowShifter & 0xFF00) | P.patternLowLatch
+	P.patternHighShifter = (P.patternHighShifter & 0xFF00) | P.patternHighLatch
+	P.attributeLowShifter = (P.attributeLowShifter & 0xFF00) | B2ix(P.attributeLatch&0x01 > 0, 0xFF, 0x00)
+	P.attributeHighShifter = (P.attributeHighShifter & 0xFF00) | B2ix(P.attributeLatch&0x02 > 0, 0xFF, 0x00)
 }
 
-func (P *Ppu) getSpriteId(tileX uint, tileY uint, offset uint) uint {
-	tileNumber := tileY*32 + tileX
-	spriteAddr := P.mirrorDownSpriteAddr(tileNumber + offset)
-	return uint(P.vram.Read(spriteAddr))
+func (P *Ppu) shiftBackground() {
+	P.patternLowShifter = (P.patternLowShifter << 1) & 0xFFFF
+	P.patternHighShifter = (P.patternHighShifter << 1) & 0xFFFF
+	P.attributeLowShifter = (P.attributeLowShifter << 1) & 0xFFFF
+	P.attributeHighShifter = (P.attributeHighShifter << 1) & 0xFFFF
 }
 
-func (P *Ppu) mirrorDownSpriteAddr(addr uint) uint {
-	if !P.isHorizontalMirror {
-		return addr
+// Coarse X wraps into the horizontally next name table.
+func (P *Ppu) incrementX() {
+	if P.vramAddr&0x001F == 31 {
+		P.vramAddr &^= 0x001F
+		P.vramAddr ^= 0x0400
+	} else {
+		P.vramAddr++
 	}
+}
 
-	if addr >= 0x0400 && addr < 0x0800 || addr >= 0x0C00 {
-		return addr - 0x400
+// Fine Y, then coarse Y wrapping at row 29 into the vertically next name table.
+// Rows 30 and 31 (attributes) wrap to 0 without switching name tables.
+func (P *Ppu) incrementY() {
+	if P.vramAddr&0x7000 != 0x7000 {
+		P.vramAddr += 0x1000
+		return
+	}
+	P.vramAddr &^= 0x7000
+	y := (P.vramAddr & 0x03E0) >> 5
+	if y == 29 {
+		y = 0
+		P.vramAddr ^= 0x0800
+	} else if y == 31 {
+		y = 0
+	} else {
+		y++
 	}
-	return addr
+	P.vramAddr = (P.vramAddr &^ 0x03E0) | y<<5
 }
 
-func (P *Ppu) buildSprites() {
-	offset := I2ix(uint(P.registers[0])&0x08, 0x1000, 0x0000)
-	for i := uint(0); i < SPRITES_NUMBER; i = i + 4 {
-		// INFO: Offset sprite Y position, because First and last 8line is not rendered.
-		y := int(P.spriteRam.Read(i)) - 8
-		// TODO: WTF
-		if y < 0 {
-			return
+func (P *Ppu) copyX() {
+	P.vramAddr = (P.vramAddr &^ 0x041F) | (P.tempVramAddr & 0x041F)
+}
+
+func (P *Ppu) copyY() {
+	P.vramAddr = (P.vramAddr &^ 0x7BE0) | (P.tempVramAddr & 0x7BE0)
+}
+
+/*
+	Sprite evaluation
+	see. https://wiki.nesdev.com/w/index.php/PPU_sprite_evaluation
+
+	The sprites of the next line are copied to secondary OAM in OAM order, up to 8.
+	After the 8th the PPU keeps looking for a 9th to set the overflow flag, but it increments
+	the byte index m along with the sprite index n, so it compares tile, attribute and X bytes
+	as Y coordinates. That is the hardware overflow bug, it gives false positives and negatives.
+
+	| step | action                                                           |
+	+------+------------------------------------------------------------------+
+	| 1    | n = 0..63: copy sprites in range to secondary OAM until 8 found  |
+	| 2    | m = 0, n continues: OAM[n*4+m] in range -> overflow, stop        |
+	|      | otherwise n++, m = (m+1) & 3 (the bug)                           |
+*/
+
+func (P *Ppu) evaluateSprites() {
+	P.spriteCount = 0
+	if P.line == 261 {
+		return
+	}
+	n := uint(0)
+	for ; n < SPRITE_COUNT && P.spriteCount < SPRITE_SLOTS; n++ {
+		P.addSprite(n)
+	}
+	next := n
+	m := uint(0)
+	for ; n < SPRITE_COUNT; n++ {
+		if P.isSpriteOnLine(uint(P.spriteRam.Read(n*4 + m))) {
+			P.setSpriteOverflow()
+			break
+		}
+		m = (m + 1) & 0x03
+	}
+	if P.isSpriteLimitDisabled {
+		// NOTE: Sprites over the limit don't exist on the hardware, they are found without the bug.
+		for n = next; n < SPRITE_COUNT; n++ {
+			P.addSprite(n)
 		}
-		spriteId := uint(P.spriteRam.Read(i + 1))
-		attr := uint(P.spriteRam.Read(i + 2))
-		x := uint(P.spriteRam.Read(i + 3))
-		sprite := P.buildSprite(spriteId, offset)
-		P.sprites[i/4] = *NewSpriteWithAttribute(sprite, x, uint(y), attr, spriteId)
 	}
 }
 
-func (P *Ppu) buildSprite(spriteId uint, offset uint) [][]uint {
-	sprite := make([][]uint, 8)
-	for index := range sprite {
-		sprite[index] = make([]uint, 8)
+// addSprite copies the sprite n of OAM to the next slot when it is on the next line.
+func (P *Ppu) addSprite(n uint) {
+	y := uint(P.spriteRam.Read(n * 4))
+	if !P.isSpriteOnLine(y) {
+		return
+	}
+	slot := &P.spriteSlots[P.spriteCount]
+	slot.x = uint(P.spriteRam.Read(n*4 + 3))
+	slot.attribute = uint(P.spriteRam.Read(n*4 + 2))
+	slot.tile = uint(P.spriteRam.Read(n*4 + 1))
+	slot.row = P.line - y
+	slot.isSpriteZero = n == 0
+	P.spriteCount++
+}
+
+// INFO: Sprites are drawn one line below their Y, so the current line selects the next line's sprites.
+func (P *Ppu) isSpriteOnLine(y uint) bool {
+	return P.line >= y && P.line-y < P.spriteHeight()
+}
+
+func (P *Ppu) spriteHeight() uint {
+	if P.isSprite8x16() {
+		return 16
 	}
+	return 8
+}
 
-	for i := uint(0); i < 16; i++ {
-		for j := uint(0); j < 8; j++ {
-			addr := spriteId*16 + i + offset
-			ram := P.ReadCharacterRAM(addr)
-			if ram&(0x80>>j) > 0 {
-				sprite[i%8][j] += 0x01 << ^^(i / 8)
-			}
+func (P *Ppu) isSprite8x16() bool {
+	return P.registers[0]&0x20 > 0
+}
+
+// SetSpriteLimit enables (the hardware behaviour) or disables the 8 sprites per line limit.
+func (P *Ppu) SetSpriteLimit(isEnabled bool) {
+	P.isSpriteLimitDisabled = !isEnabled
+}
+
+// Pattern fetches of the sprite slots during dots 257-320, empty slots fetch tile 0xFF.
+// Sprites over the limit (when it is disabled) are fetched at the last dot.
+func (P *Ppu) fetchSprites() {
+	// INFO: OAMADDR is cleared during the sprite fetches.
+	P.spriteRamAddr = 0
+	dot := P.cycle - 257
+	if dot == 63 {
+		for i := uint(SPRITE_SLOTS); i < P.spriteCount; i++ {
+			P.fetchSprite(i, 0)
+			P.fetchSprite(i, 8)
 		}
+		return
+	}
+	if dot%8 != 4 && dot%8 != 6 {
+		return
 	}
-	return sprite
-}
-
-func (P *Ppu) buildTile(tileX uint, tileY uint, offset uint) Tile {
-	// INFO see. http://hp.vector.co.jp/authors/VA042397/nes/ppu.html
-	blockId := P.getBlockId(tileX, tileY)
-	spriteId := P.getSpriteId(tileX, tileY, offset)
-	attr := P.getAttribute(tileX, tileY, offset)
-	paletteId := (attr >> (blockId * 2)) & 0x03
-	sprite := P.buildSprite(spriteId, P.backgroundTableOffset())
-	return *NewTile(
-		sprite,
-		paletteId,
-		P.scrollX,
-		P.scrollY,
-	)
-}
-
-func (P *Ppu) buildBackground() {
-	// INFO: Horizontal offsets range from 0 to 255. "Normal" vertical offsets range from 0 to 239,
-	// while values of 240 to 255 are treated as -16 through -1 in a way, but tile data is incorrectly
-	// fetched from the attribute table.
-	clampedTileY := P.tileY() % 30
-	tableIdOffset := I2ix((^^(P.tileY() / 30))%2, 2, 0)
-	// background of a line.
-	// Build viewport + 1 tile for background scroll.
-	for x := uint(0); x < 32+1; x++ {
-		tileX := x + P.scrollTileX()
-		clampedTileX := tileX % 32
-		nameTableId := ((^^(tileX / 32)) % 2) + tableIdOffset
-		offsetAddrByNameTable := nameTableId * 0x400
-		tile := P.buildTile(clampedTileX, clampedTileY, offsetAddrByNameTable)
-		P.background = append(P.background, tile)
+	high := B2ix(dot%8 == 6, 8, 0)
+	slotId := dot / 8
+	if slotId >= P.spriteCount {
+		P.ReadCharacterRAM(P.spritePatternAddr(0xFF, 0) + high)
+		return
 	}
+	P.fetchSprite(slotId, high)
 }
 
-func (P *Ppu) TransferSprite(index uint, data byte) {
-	// The DMA transfer will begin at the current OAM write address.
-	// It is common practice to initialize it to 0 with a write to PPU 0x2003 before the DMA transfer.
-	// Different starting addresses can be used for a simple OAM cycling technique
-	// to alleviate sprite priority conflicts by flickering. If using this technique
-	// after the DMA OAMADDR should be set to 0 before the end of vblank to prevent potential OAM corruption
-	// (See: Errata).
-	// However, due to OAMADDR writes also having a "corruption" effect[5] this technique is not recommended.
-	addr := index + P.spriteRamAddr
-	P.spriteRam.Write(addr%0x100, data)
+func (P *Ppu) fetchSprite(slotId uint, high uint) {
+	slot := &P.spriteSlots[slotId]
+	row := slot.row
+	if slot.attribute&0x80 > 0 {
+		row = P.spriteHeight() - 1 - row
+	}
+	data := P.ReadCharacterRAM(P.spritePatternAddr(slot.tile, row) + high)
+	if slot.attribute&0x40 > 0 {
+		data = reverseBits(data)
+	}
+	if high > 0 {
+		slot.patternHigh = data
+	} else {
+		slot.patternLow = data
+	}
 }
 
-func (P *Ppu) Run(cycle uint) *RenderingData {
-	P.cycle += cycle
-	if P.line == 0 {
-		P.background = []Tile{}
-		P.buildSprites()
+// 8x16 sprites take the pattern table from bit 0 of the tile, the bottom half is the next tile.
+func (P *Ppu) spritePatternAddr(tile uint, row uint) uint {
+	if !P.isSprite8x16() {
+		return P.spriteTableOffset() + tile*16 + row
+	}
+	table := (tile & 0x01) * 0x1000
+	tile &^= 0x01
+	if row >= 8 {
+		tile++
+		row -= 8
 	}
+	return table + tile*16 + row
+}
 
-	if P.cycle >= 341 {
-		P.cycle -= 341
-		P.line++
-		if P.hasSpriteHit() {
-			P.setSpriteHit()
+func reverseBits(b byte) byte {
+	b = (b&0xF0)>>4 | (b&0x0F)<<4
+	b = (b&0xCC)>>2 | (b&0x33)<<2
+	return (b&0xAA)>>1 | (b&0x55)<<1
+}
+
+func (P *Ppu) backgroundPixel(x uint) uint {
+	if !P.isBackgroundEnable() || x < 8 && P.registers[0x01]&0x02 == 0 {
+		return 0
+	}
+	bit := 15 - P.fineX
+	pixel := (P.patternLowShifter>>bit)&0x01 | ((P.patternHighShifter>>bit)&0x01)<<1
+	if pixel == 0 {
+		return 0
+	}
+	attribute := (P.attributeLowShifter>>bit)&0x01 | ((P.attributeHighShifter>>bit)&0x01)<<1
+	return attribute<<2 | pixel
+}
+
+// spritePixel returns the palette index (0x10-0x1F, 0 when transparent) of the front-most sprite at x.
+// INFO: The first opaque sprite in OAM order wins even when it is behind the background,
+// so a back priority sprite also hides the front priority sprites after it (used as a mask by games).
+func (P *Ppu) spritePixel(x uint) (uint, *spriteSlot) {
+	if !P.isSpriteEnable() || x < 8 && P.registers[0x01]&0x04 == 0 {
+		return 0, nil
+	}
+	for i := uint(0); i < P.spriteCount; i++ {
+		slot := &P.spriteSlots[i]
+		if x < slot.x || x-slot.x >= 8 {
+			continue
 		}
-		if P.line <= 240 && P.line%8 == 0 && P.scrollY <= 240 {
-			P.buildBackground()
+		bit := 7 - (x - slot.x)
+		pixel := uint(slot.patternLow>>bit)&0x01 | (uint(slot.patternHigh>>bit)&0x01)<<1
+		if pixel == 0 {
+			continue
 		}
-		if P.line == 241 {
-			P.setVblank()
-			if P.hasVblankIrqEnabled() {
-				P.interrupts.AssertNmi()
-			}
+		return 0x10 | (slot.attribute&0x03)<<2 | pixel, slot
+	}
+	return 0, nil
+}
+
+func (P *Ppu) putPixel(x uint, y uint) {
+	var index uint = 0
+	if P.isRendering() {
+		background := P.backgroundPixel(x)
+		sprite, slot := P.spritePixel(x)
+		if sprite > 0 && background > 0 && slot.isSpriteZero && x != 255 {
+			P.setSpriteHit()
 		}
-		if P.line == 262 {
-			P.clearVblank()
-			P.clearSpriteHit()
-			P.line = 0
-			P.interrupts.ReleaseNmi()
-			var bg []Tile = nil
-			var sprites []SpriteWithAttribute = nil
-			if P.isBackgroundEnable() {
-				bg = P.background
-			}
-			if P.isSpriteEnable() {
-				sprites = P.sprites
-			}
-			return NewRenderingData(
-				P.getPalette(),
-				bg,
-				sprites,
-			)
+		if sprite > 0 && (background == 0 || slot.attribute&0x20 == 0) {
+			index = sprite
+		} else {
+			index = background
 		}
+	} else if P.vramAddr&0x3F00 == 0x3F00 {
+		// INFO: With rendering off, the backdrop is the palette entry v points to.
+		index = P.vramAddr & 0x1F
+	}
+	color := P.palette.ram.Read(P.palette.GetPaletteAddr(index))
+	if P.registers[0x01]&0x01 > 0 {
+		// Grayscale
+		color &= 0x30
 	}
-	return nil
+	P.frame[y*SCREEN_WIDTH+x] = color & 0x3F
 }
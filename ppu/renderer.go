@@ -1,9 +1,6 @@
 package ppu
 
-import (
-	"github.com/popsul/gones/bus"
-	. "github.com/popsul/gones/common"
-)
+import "github.com/popsul/gones/bus"
 
 var COLORS = [64][3]uint8{
 	{0x80, 0x80, 0x80}, {0x00, 0x3D, 0xA6}, {0x00, 0x12, 0xB0}, {0x44, 0x00, 0x96},
@@ -26,111 +23,38 @@ var COLORS = [64][3]uint8{
 
 type Renderer struct {
 	frameBuffer []uint8
-	background  []Tile
-	serial      uint
 	drawer      Drawer
 }
 
+// The top and bottom 8 lines are hidden by the overscan of most TVs.
+const OVERSCAN = 8
+
 func NewRenderer(keypad *bus.Keypad) *Renderer {
 	R := new(Renderer)
 	R.drawer = NewSDLDrawer(keypad)
-	R.serial = 0
 	R.frameBuffer = make([]uint8, 256*256*4)
 	return R
 }
 
-func (R *Renderer) shouldPixelHide(x uint, y uint) bool {
-	// TODO: WTF??
-	//return false
-	tileX := ^^(x / 8)
-	tileY := ^^(y / 8)
-	backgroundIndex := tileY*33 + tileX
-
-	//println("xx", x, y)
-
-	sprite := uint(len(R.background)) >= backgroundIndex && R.background[backgroundIndex].Pattern != nil
-	if sprite {
-		return true
-	}
-
-	return false
-	// NOTE: If background pixel is not transparent, we need to hide sprite.
-	//return !((sprite[y % 8] && sprite[y % 8][x % 8] % 4) == 0)
-}
-
 func (R *Renderer) Render(data *RenderingData) {
-	//return
-	if data.background != nil && len(data.background) > 0 {
-		R.renderBackground(data.background, data.palette)
-	}
-
-	if data.sprites != nil && len(data.sprites) > 0 {
-		R.renderSprites(data.sprites, data.palette)
+	for y := uint(0); y < height; y++ {
+		for x := uint(0); x < SCREEN_WIDTH; x++ {
+			color := COLORS[data.frame[(y+OVERSCAN)*SCREEN_WIDTH+x]]
+			index := (x + (y * 0x100)) * 4
+			R.frameBuffer[index] = color[0]
+			R.frameBuffer[index+1] = color[1]
+			R.frameBuffer[index+2] = color[2]
+			R.frameBuffer[index+3] = 0xFF
+		}
 	}
 
 	R.drawer.Draw(R.frameBuffer)
 }
 
-func (R *Renderer) renderBackground(background []Tile, palette []byte) {
-	R.background = background
-	for i := uint(0); i < uint(len(background)); i++ {
-		x := (i % 33) * 8
-		y := ^^(i / 33) * 8
-		R.renderTile(background[i], x, y, palette)
-	}
+func (R *Renderer) IsQuit() bool {
+	return R.drawer.IsQuit()
 }
 
-func (R *Renderer) renderSprites(sprites []SpriteWithAttribute, palette []byte) {
-
-	for _, sprite := range sprites {
-		R.renderSprite(sprite, palette)
-	}
-}
-
-func (R *Renderer) renderTile(tile Tile, tileX uint, tileY uint, palette []byte) {
-	//{ sprite, paletteId, scrollX, scrollY }: Tile
-	offsetX := tile.scrollX % 8
-	offsetY := tile.scrollY % 8
-	for i := uint(0); i < 8; i++ {
-		for j := uint(0); j < 8; j++ {
-			paletteIndex := tile.paletteId*4 + tile.Pattern[i][j]
-			colorId := palette[paletteIndex]
-			color := COLORS[colorId]
-			x := tileX + j - offsetX
-			y := tileY + i - offsetY
-			if x >= 0 && 0xFF >= x && y >= 0 && y < 224 {
-				index := (x + (y * 0x100)) * 4
-				R.frameBuffer[index] = color[0]
-				R.frameBuffer[index+1] = color[1]
-				R.frameBuffer[index+2] = color[2]
-				R.frameBuffer[index+3] = 0xFF
-			}
-		}
-	}
-}
-
-func (R *Renderer) renderSprite(sprite SpriteWithAttribute, palette []byte) {
-	isVerticalReverse := I2b(sprite.attribute & 0x80)
-	isHorizontalReverse := I2b(sprite.attribute & 0x40)
-	isLowPriority := I2b(sprite.attribute & 0x20)
-	paletteId := sprite.attribute & 0x03
-	for i := uint(0); i < 8; i++ {
-		for j := uint(0); j < 8; j++ {
-			x := sprite.x + B2ix(isHorizontalReverse, 7-j, j)
-			y := sprite.y + B2ix(isVerticalReverse, 7-i, i)
-			if isLowPriority && R.shouldPixelHide(x, y) {
-				continue
-			}
-			if sprite.sprite != nil && sprite.sprite[i][j] > 0 {
-				colorId := palette[paletteId*4+sprite.sprite[i][j]+0x10]
-				color := COLORS[colorId]
-				index := (x + y*0x100) * 4
-				R.frameBuffer[index] = color[0]
-				R.frameBuffer[index+1] = color[1]
-				R.frameBuffer[index+2] = color[2]
-				//R.frameBuffer[index+3] = 0xff
-				// data[index + 3] = 0xFF;
-			}
-		}
-	}
+func (R *Renderer) IsResetRequested() bool {
+	return R.drawer.IsResetRequested()
 }
@@ -0,0 +1,84 @@
+package reader
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+var ErrNoRomInArchive = errors.New("no .nes or .unf file in archive")
+
+var RomExtensions = []string{".nes", ".unf", ".unif"}
+
+// ReadImage returns the raw ROM image of the file. Zip and gzip archives are detected
+// by their signature and unpacked, entry selects a file in a zip archive
+// (the first .nes or .unf file is used when entry is empty).
+func ReadImage(file string, entry string) ([]byte, error) {
+	buffer, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Unpack(buffer, entry)
+}
+
+func Unpack(buffer []byte, entry string) ([]byte, error) {
+	if bytes.HasPrefix(buffer, []byte("PK\x03\x04")) {
+		return unpackZip(buffer, entry)
+	}
+	if bytes.HasPrefix(buffer, []byte{0x1F, 0x8B}) {
+		return unpackGzip(buffer)
+	}
+	return buffer, nil
+}
+
+func unpackZip(buffer []byte, entry string) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(buffer), int64(len(buffer)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if entry != "" && f.Name != entry && path.Base(f.Name) != entry {
+			continue
+		}
+		if entry == "" && !isRomFile(f.Name) {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	if entry != "" {
+		return nil, fmt.Errorf("%s not found in archive", entry)
+	}
+	return nil, ErrNoRomInArchive
+}
+
+func unpackGzip(buffer []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(buffer))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func isRomFile(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, romExt := range RomExtensions {
+		if ext == romExt {
+			return true
+		}
+	}
+	return false
+}
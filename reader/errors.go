@@ -0,0 +1,49 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrBadMagic           = errors.New("bad magic, not an iNES or UNIF file")
+	ErrTruncatedHeader    = errors.New("truncated header")
+	ErrTruncatedTrainer   = errors.New("truncated trainer")
+	ErrTruncatedProgram   = errors.New("truncated PRG ROM")
+	ErrTruncatedCharacter = errors.New("truncated CHR ROM")
+	ErrTruncatedChunk     = errors.New("truncated UNIF chunk")
+	ErrEmptyProgram       = errors.New("no PRG ROM")
+)
+
+// UnsupportedMapperError is returned when no mapper implementation exists for the cartridge board.
+type UnsupportedMapperError struct {
+	Mapper    uint
+	SubMapper uint
+}
+
+func (E *UnsupportedMapperError) Error() string {
+	return fmt.Sprintf("unsupported mapper %d.%d", E.Mapper, E.SubMapper)
+}
+
+// UnsupportedBoardError is returned for UNIF boards without a mapper number.
+type UnsupportedBoardError struct {
+	Board string
+}
+
+func (E *UnsupportedBoardError) Error() string {
+	return fmt.Sprintf("unsupported UNIF board %s", E.Board)
+}
+
+// UnsupportedMirroringError is returned for UNIF MIRR values the board can't have.
+type UnsupportedMirroringError struct {
+	Board     string
+	Mirroring byte
+}
+
+func (E *UnsupportedMirroringError) Error() string {
+	return fmt.Sprintf("unsupported mirroring %d for UNIF board %s", E.Mirroring, E.Board)
+}
+
+func truncated(err error, want uint, have uint) error {
+	return fmt.Errorf("%w: need %d bytes, got %d", err, want, have)
+}
@@ -0,0 +1,246 @@
+package reader
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// GameInfo is an entry of the game database, keyed by CRC32 or SHA-1 of PRG+CHR ROM (without header).
+// Set fields override the header, so dumps with wrong mapper or mirroring bits run correctly.
+type GameInfo struct {
+	Crc32  string `json:"crc32,omitempty" xml:"crc32,attr,omitempty"`
+	Sha1   string `json:"sha1,omitempty" xml:"sha1,attr,omitempty"`
+	Title  string `json:"title" xml:"title,attr"`
+	Region string `json:"region,omitempty" xml:"region,attr,omitempty"`
+
+	Mapper           *uint `json:"mapper,omitempty" xml:"mapper,attr,omitempty"`
+	SubMapper        *uint `json:"submapper,omitempty" xml:"submapper,attr,omitempty"`
+	Battery          *bool `json:"battery,omitempty" xml:"battery,attr,omitempty"`
+	ProgramRamSize   *uint `json:"prgRam,omitempty" xml:"prgRam,attr,omitempty"`
+	ProgramNvramSize *uint `json:"prgNvram,omitempty" xml:"prgNvram,attr,omitempty"`
+	CharacterRamSize *uint `json:"chrRam,omitempty" xml:"chrRam,attr,omitempty"`
+	// "horizontal", "vertical" or "four-screen"
+	Mirroring string `json:"mirroring,omitempty" xml:"mirroring,attr,omitempty"`
+	// "NTSC", "PAL", "multi-region" or "Dendy"
+	TvSystem string `json:"tv,omitempty" xml:"tv,attr,omitempty"`
+}
+
+// NOTE: There is no built-in database, the entries come from the file given to LoadGameDatabase
+// (the -gamedb option), e.g. the XML export of NesCartDB (https://nescartdb.com), see. nesCartDbCartridge
+// Files of our own format use the GameInfo fields:
+//
+//	JSON: [{"crc32": "3337EC46", "title": "Super Mario Bros.", "mapper": 0, "mirroring": "vertical"}]
+//	XML:  <database><game crc32="3337EC46" title="Super Mario Bros." mapper="0" mirroring="vertical"/></database>
+
+type gameDatabaseXml struct {
+	Games []gameXml `xml:"game"`
+}
+
+// A <game> element, either an entry of our format or a NesCartDB game with its cartridges.
+type gameXml struct {
+	GameInfo
+	Name       string               `xml:"name,attr"`
+	Cartridges []nesCartDbCartridge `xml:"cartridge"`
+}
+
+/*
+	NesCartDB cartridge
+	see. https://nescartdb.com
+
+	<game name="..." region="USA">
+		<cartridge system="NES-NTSC" crc="..." sha1="...">
+			<board type="NES-SNROM" mapper="1">
+				<wram size="8k" battery="1"/>
+				<vram size="8k"/>
+				<pad h="0" v="1"/>
+			</board>
+		</cartridge>
+	</game>
+
+	| element   | field                                                      |
+	+-----------+------------------------------------------------------------+
+	| cartridge | system: NES-NTSC, NES-PAL, NES-PAL-A, NES-PAL-B, Famicom   |
+	|           | crc, sha1: PRG+CHR ROM                                     |
+	| board     | mapper                                                     |
+	| wram      | PRG RAM, NVRAM when battery="1"                            |
+	| vram      | CHR RAM                                                    |
+	| pad       | solder pads named after the mirroring they select, absent  |
+	|           | when the mapper controls the mirroring                     |
+*/
+
+type nesCartDbCartridge struct {
+	System string `xml:"system,attr"`
+	Crc32  string `xml:"crc,attr"`
+	Sha1   string `xml:"sha1,attr"`
+	Board  struct {
+		Mapper *uint `xml:"mapper,attr"`
+		Wram   []struct {
+			Size    string `xml:"size,attr"`
+			Battery bool   `xml:"battery,attr"`
+		} `xml:"wram"`
+		Vram []struct {
+			Size string `xml:"size,attr"`
+		} `xml:"vram"`
+		Pad *struct {
+			Horizontal bool `xml:"h,attr"`
+			Vertical   bool `xml:"v,attr"`
+		} `xml:"pad"`
+	} `xml:"board"`
+}
+
+func (G *gameXml) games() []GameInfo {
+	if len(G.Cartridges) == 0 {
+		return []GameInfo{G.GameInfo}
+	}
+	var games []GameInfo
+	for _, cartridge := range G.Cartridges {
+		game := GameInfo{
+			Crc32:  cartridge.Crc32,
+			Sha1:   cartridge.Sha1,
+			Title:  G.Name,
+			Region: G.Region,
+			Mapper: cartridge.Board.Mapper,
+		}
+		var ram, nvram, chrRam uint
+		for _, wram := range cartridge.Board.Wram {
+			if wram.Battery {
+				nvram += parseSize(wram.Size)
+			} else {
+				ram += parseSize(wram.Size)
+			}
+		}
+		for _, vram := range cartridge.Board.Vram {
+			chrRam += parseSize(vram.Size)
+		}
+		battery := nvram > 0
+		game.Battery = &battery
+		game.ProgramRamSize = &ram
+		game.ProgramNvramSize = &nvram
+		game.CharacterRamSize = &chrRam
+		if pad := cartridge.Board.Pad; pad != nil {
+			if pad.Horizontal {
+				game.Mirroring = "horizontal"
+			} else if pad.Vertical {
+				game.Mirroring = "vertical"
+			}
+		}
+		if strings.HasPrefix(cartridge.System, "NES-PAL") {
+			game.TvSystem = TvSystemName[TvSystemPal]
+		} else if cartridge.System != "" {
+			game.TvSystem = TvSystemName[TvSystemNtsc]
+		}
+		games = append(games, game)
+	}
+	return games
+}
+
+// parseSize reads the NesCartDB sizes, "8k" or a number of bytes.
+func parseSize(size string) uint {
+	unit := uint(1)
+	size = strings.ToLower(size)
+	if strings.HasSuffix(size, "k") {
+		unit = 1024
+		size = strings.TrimSuffix(size, "k")
+	}
+	n, err := strconv.ParseUint(size, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint(n) * unit
+}
+
+var gameDatabase []GameInfo
+
+// LoadGameDatabase adds entries of a JSON or XML file, they take precedence over the ones loaded before.
+func LoadGameDatabase(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var games []GameInfo
+	if strings.ToLower(filepath.Ext(file)) == ".xml" {
+		var db gameDatabaseXml
+		err = xml.Unmarshal(data, &db)
+		for i := range db.Games {
+			games = append(games, db.Games[i].games()...)
+		}
+	} else {
+		err = json.Unmarshal(data, &games)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	gameDatabase = append(games, gameDatabase...)
+	return nil
+}
+
+func LookupGame(crc uint32, sha string) *GameInfo {
+	for i := range gameDatabase {
+		game := &gameDatabase[i]
+		if game.Sha1 != "" && strings.EqualFold(game.Sha1, sha) {
+			return game
+		}
+		if game.Crc32 != "" && strings.EqualFold(game.Crc32, fmt.Sprintf("%08X", crc)) {
+			return game
+		}
+	}
+	return nil
+}
+
+func (N *NesRom) hash() (uint32, string) {
+	crc := crc32.NewIEEE()
+	crc.Write(N.Program)
+	crc.Write(N.Character)
+	sha := sha1.New()
+	sha.Write(N.Program)
+	sha.Write(N.Character)
+	return crc.Sum32(), hex.EncodeToString(sha.Sum(nil))
+}
+
+func (H *Header) override(game *GameInfo) {
+	if game.Mapper != nil {
+		H.Mapper = *game.Mapper
+	}
+	if game.SubMapper != nil {
+		H.SubMapper = *game.SubMapper
+	}
+	if game.ProgramRamSize != nil {
+		H.ProgramRamSize = *game.ProgramRamSize
+	}
+	if game.ProgramNvramSize != nil {
+		H.ProgramNvramSize = *game.ProgramNvramSize
+	}
+	if game.CharacterRamSize != nil {
+		H.CharacterRamSize = *game.CharacterRamSize
+	}
+	if game.Battery != nil {
+		H.Battery = *game.Battery
+		// Keep the RAM, only its kind changes.
+		if H.Battery && H.ProgramNvramSize == 0 {
+			H.ProgramRamSize, H.ProgramNvramSize = 0, H.ProgramRamSize
+		} else if !H.Battery && H.ProgramRamSize == 0 {
+			H.ProgramRamSize, H.ProgramNvramSize = H.ProgramNvramSize, 0
+		}
+	}
+	switch game.Mirroring {
+	case "horizontal":
+		H.HorizontalMirror, H.FourScreen, H.SingleScreen = true, false, false
+	case "vertical":
+		H.HorizontalMirror, H.FourScreen, H.SingleScreen = false, false, false
+	case "four-screen":
+		H.FourScreen, H.SingleScreen = true, false
+	}
+	for tv, name := range TvSystemName {
+		if strings.EqualFold(game.TvSystem, name) {
+			H.TvSystem = tv
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package reader
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func uintPtr(n uint) *uint {
+	return &n
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestOverride(t *testing.T) {
+	tests := []struct {
+		name   string
+		header Header
+		game   GameInfo
+		want   Header
+	}{
+		{
+			"nothing set",
+			Header{Mapper: 1, HorizontalMirror: true},
+			GameInfo{Title: "Unchanged"},
+			Header{Mapper: 1, HorizontalMirror: true},
+		},
+		{
+			"mapper and mirroring",
+			Header{Mapper: 0, HorizontalMirror: true},
+			GameInfo{Mapper: uintPtr(4), SubMapper: uintPtr(1), Mirroring: "vertical"},
+			Header{Mapper: 4, SubMapper: 1},
+		},
+		{
+			"horizontal clears four-screen",
+			Header{FourScreen: true},
+			GameInfo{Mirroring: "horizontal"},
+			Header{HorizontalMirror: true},
+		},
+		{
+			"four-screen",
+			Header{HorizontalMirror: true},
+			GameInfo{Mirroring: "four-screen"},
+			Header{HorizontalMirror: true, FourScreen: true},
+		},
+		{
+			"battery keeps the RAM size",
+			Header{ProgramRamSize: 0x2000},
+			GameInfo{Battery: boolPtr(true)},
+			Header{Battery: true, ProgramNvramSize: 0x2000},
+		},
+		{
+			"no battery keeps the RAM size",
+			Header{Battery: true, ProgramNvramSize: 0x2000},
+			GameInfo{Battery: boolPtr(false)},
+			Header{ProgramRamSize: 0x2000},
+		},
+		{
+			"RAM sizes and TV system",
+			Header{ProgramRamSize: 0x2000},
+			GameInfo{ProgramRamSize: uintPtr(0), ProgramNvramSize: uintPtr(0x8000), CharacterRamSize: uintPtr(0x2000), TvSystem: "pal"},
+			Header{ProgramNvramSize: 0x8000, CharacterRamSize: 0x2000, TvSystem: TvSystemPal},
+		},
+	}
+	for _, test := range tests {
+		header := test.header
+		header.override(&test.game)
+		if header != test.want {
+			t.Errorf("%s: %+v, want %+v", test.name, header, test.want)
+		}
+	}
+}
+
+// An NROM-128 image with horizontal mirroring in the header.
+func newTestImage() []byte {
+	image := []byte{'N', 'E', 'S', 0x1A, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	image = append(image, make([]byte, PROGRAM_ROM_SIZE+CHARACTER_ROM_SIZE)...)
+	for i := range image[NES_HEADER_SIZE:] {
+		image[NES_HEADER_SIZE+i] = byte(i * 7)
+	}
+	return image
+}
+
+func TestLoadGameDatabase(t *testing.T) {
+	image := newTestImage()
+	rom, err := Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rom.Game != nil {
+		t.Fatalf("found %q without a database", rom.Game.Title)
+	}
+	crc, sha := fmt.Sprintf("%08x", rom.Crc32), rom.Sha1
+
+	tests := []struct {
+		name     string
+		file     string
+		data     string
+		title    string
+		mapper   uint
+		vertical bool
+		battery  bool
+		nvram    uint
+		tv       TvSystem
+	}{
+		{
+			"JSON by CRC32",
+			"db.json",
+			`[{"crc32": "` + crc + `", "title": "JSON", "mapper": 2, "mirroring": "vertical"}]`,
+			"JSON", 2, true, false, 0, TvSystemNtsc,
+		},
+		{
+			"JSON by SHA-1",
+			"db.json",
+			`[{"sha1": "` + sha + `", "title": "SHA-1", "battery": true, "prgRam": 8192}]`,
+			"SHA-1", 0, false, true, 0x2000, TvSystemNtsc,
+		},
+		{
+			"XML",
+			"db.XML",
+			`<database><game crc32="` + crc + `" title="XML" mapper="4" mirroring="vertical" tv="PAL"/></database>`,
+			"XML", 4, true, false, 0, TvSystemPal,
+		},
+		{
+			"NesCartDB XML",
+			"nescartdb.xml",
+			`<database version="1.0">
+				<game name="Other" region="USA">
+					<cartridge system="NES-NTSC" crc="00000000"><board mapper="3"/></cartridge>
+				</game>
+				<game name="NesCartDB" region="Europe">
+					<cartridge system="NES-PAL-B" crc="` + crc + `" sha1="` + sha + `">
+						<board type="NES-SNROM" mapper="1">
+							<prg size="16k"/>
+							<chr size="8k"/>
+							<wram size="8k" battery="1"/>
+							<pad h="0" v="1"/>
+						</board>
+					</cartridge>
+				</game>
+			</database>`,
+			"NesCartDB", 1, true, true, 0x2000, TvSystemPal,
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "gamedb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(database []GameInfo) {
+		gameDatabase = database
+	}(gameDatabase)
+	for _, test := range tests {
+		file := filepath.Join(dir, test.file)
+		if err := ioutil.WriteFile(file, []byte(test.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := LoadGameDatabase(file); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		rom, err := Parse(image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rom.Game == nil || rom.Game.Title != test.title {
+			t.Errorf("%s: game %+v, want %q", test.name, rom.Game, test.title)
+			continue
+		}
+		if rom.Mapper != test.mapper || rom.HorizontalMirror == test.vertical || rom.Battery != test.battery ||
+			rom.ProgramNvramSize != test.nvram || rom.TvSystem != test.tv {
+			t.Errorf("%s: %+v", test.name, rom.Header)
+		}
+	}
+
+	file := filepath.Join(dir, "broken.json")
+	if err := ioutil.WriteFile(file, []byte(`[{"crc32": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadGameDatabase(file); err == nil {
+		t.Error("broken.json: no error")
+	}
+}
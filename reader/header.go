@@ -0,0 +1,216 @@
+package reader
+
+/*
+	iNES / NES 2.0 header
+	see. https://wiki.nesdev.com/w/index.php/NES_2.0
+
+	| byte | description                                                 |
+	+------+-------------------------------------------------------------+
+	| 0-3  | "NES" 0x1A                                                  |
+	| 4    | PRG ROM size LSB (16K units)                                |
+	| 5    | CHR ROM size LSB (8K units)                                 |
+	| 6    | MMMMFTBV  mapper D0-D3, four-screen, trainer, battery,      |
+	|      |           nametable arrangement (0: horizontal mirroring)   |
+	| 7    | MMMMVVCC  mapper D4-D7, 0b10: NES 2.0, console type         |
+	| 8    | NES 2.0: SSSSMMMM submapper, mapper D8-D11                  |
+	|      | iNES:    PRG RAM size (8K units)                            |
+	| 9    | NES 2.0: CCCCPPPP CHR / PRG ROM size MSB                    |
+	|      | iNES:    bit 0 TV system                                    |
+	| 10   | NES 2.0: PRG NVRAM / PRG RAM shift count                    |
+	| 11   | NES 2.0: CHR NVRAM / CHR RAM shift count                    |
+	| 12   | NES 2.0: CPU/PPU timing                                     |
+	| 13   | NES 2.0: Vs. System type or extended console type           |
+	| 14   | NES 2.0: number of miscellaneous ROMs                       |
+	| 15   | NES 2.0: default expansion device                           |
+*/
+
+const TRAINER_SIZE = 0x0200
+
+type Format uint
+
+const (
+	FormatArchaicINes = Format(iota)
+	FormatINes
+	FormatNes20
+	FormatUnif
+)
+
+var FormatName = map[Format]string{
+	FormatArchaicINes: "archaic iNES",
+	FormatINes:        "iNES",
+	FormatNes20:       "NES 2.0",
+	FormatUnif:        "UNIF",
+}
+
+type TvSystem uint
+
+const (
+	TvSystemNtsc = TvSystem(iota)
+	TvSystemPal
+	TvSystemMultiRegion
+	TvSystemDendy
+)
+
+var TvSystemName = map[TvSystem]string{
+	TvSystemNtsc:        "NTSC",
+	TvSystemPal:         "PAL",
+	TvSystemMultiRegion: "multi-region",
+	TvSystemDendy:       "Dendy",
+}
+
+type ConsoleType uint
+
+const (
+	ConsoleTypeNes = ConsoleType(iota)
+	ConsoleTypeVsSystem
+	ConsoleTypePlaychoice10
+	ConsoleTypeExtended
+)
+
+var ConsoleTypeName = map[ConsoleType]string{
+	ConsoleTypeNes:          "NES/Famicom",
+	ConsoleTypeVsSystem:     "Vs. System",
+	ConsoleTypePlaychoice10: "PlayChoice-10",
+	ConsoleTypeExtended:     "extended",
+}
+
+type Header struct {
+	Format Format
+	// Bytes 7-15 of some old dumps contain a ripper signature ("DiskDude!") instead of zeros.
+	HasGarbage bool
+
+	Mapper    uint
+	SubMapper uint
+
+	ProgramRomPages    uint
+	CharacterRomPages  uint
+	ProgramRomSize     uint
+	CharacterRomSize   uint
+	ProgramRamSize     uint
+	ProgramNvramSize   uint
+	CharacterRamSize   uint
+	CharacterNvramSize uint
+
+	HorizontalMirror bool
+	FourScreen       bool
+	// UNIF only: the nametables are all mapped to CIRAM page SingleScreenPage (0: A, 1: B).
+	SingleScreen     bool
+	SingleScreenPage uint
+	Battery          bool
+	HasTrainer       bool
+
+	TvSystem            TvSystem
+	ConsoleType         ConsoleType
+	VsPpuType           uint
+	VsHardwareType      uint
+	ExtendedConsoleType uint
+	MiscRoms            uint
+	ExpansionDevice     uint
+}
+
+// ParseHeader decodes the 16 byte header, buffer must be at least NES_HEADER_SIZE long.
+func ParseHeader(buffer []byte) *Header {
+	header := new(Header)
+	header.Format = detectFormat(buffer)
+
+	flags6 := uint(buffer[6])
+	header.HorizontalMirror = flags6&0x01 != 1
+	header.Battery = flags6&0x02 > 0
+	header.HasTrainer = flags6&0x04 > 0
+	header.FourScreen = flags6&0x08 > 0
+	header.Mapper = flags6 >> 4
+
+	switch header.Format {
+	case FormatArchaicINes:
+		header.HasGarbage = true
+		header.ProgramRomSize = uint(buffer[4]) * PROGRAM_ROM_SIZE
+		header.CharacterRomSize = uint(buffer[5]) * CHARACTER_ROM_SIZE
+		header.ProgramRamSize = PROGRAM_RAM_SIZE
+	case FormatINes:
+		header.parseINes(buffer)
+	case FormatNes20:
+		header.parseNes20(buffer)
+	}
+
+	// Battery backed RAM is reported as NVRAM in NES 2.0, move it there for old headers as well.
+	if header.Format != FormatNes20 && header.Battery {
+		header.ProgramNvramSize = header.ProgramRamSize
+		header.ProgramRamSize = 0
+	}
+	if header.Format != FormatNes20 && header.CharacterRomSize == 0 {
+		header.CharacterRamSize = CHARACTER_ROM_SIZE
+	}
+
+	header.ProgramRomPages = header.ProgramRomSize / PROGRAM_ROM_SIZE
+	header.CharacterRomPages = header.CharacterRomSize / CHARACTER_ROM_SIZE
+	return header
+}
+
+// see. https://wiki.nesdev.com/w/index.php/NES_2.0#Identification
+func detectFormat(buffer []byte) Format {
+	if buffer[7]&0x0C == 0x08 {
+		return FormatNes20
+	}
+	if buffer[7]&0x0C == 0x00 && buffer[12] == 0 && buffer[13] == 0 && buffer[14] == 0 && buffer[15] == 0 {
+		return FormatINes
+	}
+	return FormatArchaicINes
+}
+
+func (H *Header) parseINes(buffer []byte) {
+	flags7 := uint(buffer[7])
+	H.Mapper |= flags7 & 0xF0
+	H.ConsoleType = ConsoleType(flags7 & 0x03)
+	H.ProgramRomSize = uint(buffer[4]) * PROGRAM_ROM_SIZE
+	H.CharacterRomSize = uint(buffer[5]) * CHARACTER_ROM_SIZE
+	// 0 means 8K for compatibility
+	H.ProgramRamSize = uint(buffer[8]) * PROGRAM_RAM_SIZE
+	if H.ProgramRamSize == 0 {
+		H.ProgramRamSize = PROGRAM_RAM_SIZE
+	}
+	if buffer[9]&0x01 > 0 {
+		H.TvSystem = TvSystemPal
+	}
+}
+
+func (H *Header) parseNes20(buffer []byte) {
+	flags7 := uint(buffer[7])
+	H.Mapper |= flags7&0xF0 | (uint(buffer[8])&0x0F)<<8
+	H.SubMapper = uint(buffer[8]) >> 4
+	H.ConsoleType = ConsoleType(flags7 & 0x03)
+
+	H.ProgramRomSize = romSize(uint(buffer[4]), uint(buffer[9])&0x0F, PROGRAM_ROM_SIZE)
+	H.CharacterRomSize = romSize(uint(buffer[5]), uint(buffer[9])>>4, CHARACTER_ROM_SIZE)
+
+	H.ProgramRamSize = ramSize(uint(buffer[10]) & 0x0F)
+	H.ProgramNvramSize = ramSize(uint(buffer[10]) >> 4)
+	H.CharacterRamSize = ramSize(uint(buffer[11]) & 0x0F)
+	H.CharacterNvramSize = ramSize(uint(buffer[11]) >> 4)
+
+	H.TvSystem = TvSystem(buffer[12] & 0x03)
+	switch H.ConsoleType {
+	case ConsoleTypeVsSystem:
+		H.VsPpuType = uint(buffer[13]) & 0x0F
+		H.VsHardwareType = uint(buffer[13]) >> 4
+	case ConsoleTypeExtended:
+		H.ExtendedConsoleType = uint(buffer[13]) & 0x0F
+	}
+	H.MiscRoms = uint(buffer[14]) & 0x03
+	H.ExpansionDevice = uint(buffer[15]) & 0x3F
+}
+
+// When the MSB nibble is 0xF, the LSB byte is EEEEEEMM and the size is 2^E * (MM*2+1) bytes.
+func romSize(lsb uint, msb uint, unit uint) uint {
+	if msb != 0x0F {
+		return (msb<<8 | lsb) * unit
+	}
+	return (1 << (lsb >> 2)) * ((lsb&0x03)*2 + 1)
+}
+
+// Shift count 0 means no RAM, otherwise 64 << shift bytes.
+func ramSize(shift uint) uint {
+	if shift == 0 {
+		return 0
+	}
+	return 64 << shift
+}
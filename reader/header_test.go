@@ -0,0 +1,83 @@
+package reader
+
+import "testing"
+
+// newHeader returns a header with bytes 4-15 set to data.
+func newHeader(data ...byte) []byte {
+	buffer := make([]byte, NES_HEADER_SIZE)
+	copy(buffer, []byte{'N', 'E', 'S', 0x1A})
+	copy(buffer[4:], data)
+	return buffer
+}
+
+func TestParseHeaderFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		buffer  []byte
+		format  Format
+		mapper  uint
+		garbage bool
+	}{
+		{"iNES", newHeader(2, 1, 0x10, 0x40), FormatINes, 0x41, false},
+		{"NES 2.0", newHeader(2, 1, 0x10, 0x48, 0x01), FormatNes20, 0x141, false},
+		{"DiskDude!", []byte("NES\x1a\x02\x01\x10DiskDude!"), FormatArchaicINes, 0x01, true},
+		{"garbage in bytes 12-15", newHeader(2, 1, 0x10, 0x40, 0, 0, 0, 0, 0, 0, 0, 'X'), FormatArchaicINes, 0x01, true},
+		{"byte 7 bits 2-3 set", newHeader(2, 1, 0x10, 0x4C), FormatArchaicINes, 0x01, true},
+	}
+	for _, test := range tests {
+		header := ParseHeader(test.buffer)
+		if header.Format != test.format || header.Mapper != test.mapper || header.HasGarbage != test.garbage {
+			t.Errorf("%s: format %s, mapper %d, garbage %t, want %s, %d, %t", test.name,
+				FormatName[header.Format], header.Mapper, header.HasGarbage,
+				FormatName[test.format], test.mapper, test.garbage)
+		}
+	}
+}
+
+func TestParseHeaderSizes(t *testing.T) {
+	tests := []struct {
+		name         string
+		buffer       []byte
+		programRom   uint
+		characterRom uint
+		programRam   uint
+		programNvram uint
+		characterRam uint
+	}{
+		{"iNES", newHeader(2, 1, 0x00, 0x00, 2), 0x8000, 0x2000, 0x4000, 0, 0},
+		{"iNES PRG RAM 0 is 8K", newHeader(1, 0, 0x00), 0x4000, 0, 0x2000, 0, 0x2000},
+		{"iNES battery", newHeader(1, 1, 0x02), 0x4000, 0x2000, 0, 0x2000, 0},
+		{"archaic", []byte("NES\x1a\x02\x00\x12DiskDude!"), 0x8000, 0, 0, 0x2000, 0x2000},
+		{"NES 2.0 MSB", newHeader(0x00, 0x00, 0x00, 0x08, 0x00, 0x21, 0x07, 0x70), 0x100 * 0x4000, 0x200 * 0x2000, 0x2000, 0, 0},
+		{"NES 2.0 RAM shift", newHeader(2, 0, 0x02, 0x08, 0x00, 0x00, 0x70, 0x07), 0x8000, 0, 0, 0x2000, 0x2000},
+		// EEEEEEMM: 2^19 * 5 and 2^10 * 3
+		{"NES 2.0 exponent", newHeader(0x4E, 0x29, 0x00, 0x08, 0x00, 0xFF), (1 << 19) * 5, (1 << 10) * 3, 0, 0, 0},
+		{"NES 2.0 exponent MM 0", newHeader(0x3C, 0x00, 0x00, 0x08, 0x00, 0x0F), 1 << 15, 0, 0, 0, 0},
+	}
+	for _, test := range tests {
+		header := ParseHeader(test.buffer)
+		if header.ProgramRomSize != test.programRom || header.CharacterRomSize != test.characterRom {
+			t.Errorf("%s: PRG ROM %d, CHR ROM %d, want %d, %d", test.name,
+				header.ProgramRomSize, header.CharacterRomSize, test.programRom, test.characterRom)
+		}
+		if header.ProgramRamSize != test.programRam || header.ProgramNvramSize != test.programNvram || header.CharacterRamSize != test.characterRam {
+			t.Errorf("%s: PRG RAM %d, PRG NVRAM %d, CHR RAM %d, want %d, %d, %d", test.name,
+				header.ProgramRamSize, header.ProgramNvramSize, header.CharacterRamSize,
+				test.programRam, test.programNvram, test.characterRam)
+		}
+	}
+}
+
+func TestParseHeaderNes20(t *testing.T) {
+	header := ParseHeader(newHeader(2, 1, 0x41, 0x49, 0x31, 0x00, 0x00, 0x00, 0x01, 0x21, 0x02, 0x05))
+	if header.Mapper != 0x144 || header.SubMapper != 3 || header.ConsoleType != ConsoleTypeVsSystem {
+		t.Errorf("mapper %d.%d, console %s", header.Mapper, header.SubMapper, ConsoleTypeName[header.ConsoleType])
+	}
+	if header.HorizontalMirror || header.TvSystem != TvSystemPal || header.VsPpuType != 1 || header.VsHardwareType != 2 {
+		t.Errorf("horizontal %t, TV system %s, Vs. PPU %d, Vs. hardware %d", header.HorizontalMirror,
+			TvSystemName[header.TvSystem], header.VsPpuType, header.VsHardwareType)
+	}
+	if header.MiscRoms != 2 || header.ExpansionDevice != 5 {
+		t.Errorf("misc ROMs %d, expansion device %d", header.MiscRoms, header.ExpansionDevice)
+	}
+}
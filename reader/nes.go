@@ -1,71 +1,162 @@
 package reader
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"os"
 )
 
 const NES_HEADER_SIZE = 0x0010
 const PROGRAM_ROM_SIZE = 0x4000
 const CHARACTER_ROM_SIZE = 0x2000
+const PROGRAM_RAM_SIZE = 0x2000
 
 type NesRom struct {
-	HorizontalMirror  bool
-	Program           []byte
-	Character         []byte
-	ProgramRomPages   uint
-	CharacterRomPages uint
-	Mapper            uint
+	Header
+	Trainer   []byte
+	Program   []byte
+	Character []byte
+	Crc32     uint32
+	Sha1      string
+	// UNIF board name, empty for iNES
+	Board string
+	// Patch file applied by Load, empty when there is none.
+	Patch string
+	// Game database entry, nil when the ROM is unknown.
+	Game *GameInfo
 }
 
-func ReadRom(file string) *NesRom {
-	f, err := os.Open(file)
+// ReadRom loads a .nes file, or the ROM inside a .zip/.gz archive.
+// A .ips/.ups/.bps file next to it is applied.
+func ReadRom(file string) (*NesRom, error) {
+	return Load(file, "", "")
+}
+
+// Load reads the ROM image, unpacks the archive entry and applies the patch before parsing.
+// When patch is empty, a patch with the same base name as the file is looked up.
+func Load(file string, entry string, patch string) (*NesRom, error) {
+	buffer, err := ReadImage(file, entry)
+	if err != nil {
+		return nil, err
+	}
+	if patch == "" {
+		patch = FindPatch(file)
+	}
+	if patch != "" {
+		buffer, err = ApplyPatchFile(buffer, patch)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", patch, err)
+		}
+	}
+	rom, err := Parse(buffer)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	rom.Patch = patch
+	return rom, nil
+}
 
-	buffer, err := ioutil.ReadAll(f)
+func Read(r io.Reader) (*NesRom, error) {
+	buffer, err := ioutil.ReadAll(r)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	buffer, err = Unpack(buffer, "")
+	if err != nil {
+		return nil, err
+	}
+	return Parse(buffer)
+}
 
-	if buffer[0] != 'N' || buffer[1] != 'E' || buffer[2] != 'S' {
-		panic(errors.New("Invalid NES file"))
+// Parse decodes an iNES or UNIF image. Returned ROM slices share memory with the buffer.
+func Parse(buffer []byte) (*NesRom, error) {
+	var rom *NesRom
+	var err error
+	if bytes.HasPrefix(buffer, []byte(UNIF_MAGIC)) {
+		rom, err = parseUnif(buffer)
+	} else {
+		rom, err = parseINes(buffer)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("Rom size: %d (0x%x)\n", len(buffer), len(buffer))
+	rom.Crc32, rom.Sha1 = rom.hash()
+	rom.Game = LookupGame(rom.Crc32, rom.Sha1)
+	if rom.Game != nil {
+		rom.Header.override(rom.Game)
+	}
 
-	rom := new(NesRom)
-	rom.ProgramRomPages = uint(buffer[4])
-	rom.CharacterRomPages = uint(buffer[5])
-	rom.HorizontalMirror = uint(buffer[6])&0x01 != 1
-	rom.Mapper = (uint(buffer[6])&0xf0)>>4 | (uint(buffer[7]) & 0xf0)
+	return rom, nil
+}
 
-	fmt.Printf("Program ROM pages: %d\n", rom.ProgramRomPages)
-	fmt.Printf("Character ROM pages: %d\n", rom.CharacterRomPages)
-	fmt.Printf("Mapper: %d\n", rom.Mapper)
+func parseINes(buffer []byte) (*NesRom, error) {
+	size := uint(len(buffer))
+	if size < 4 || !bytes.Equal(buffer[0:4], []byte{'N', 'E', 'S', 0x1A}) {
+		return nil, ErrBadMagic
+	}
+	if size < NES_HEADER_SIZE {
+		return nil, truncated(ErrTruncatedHeader, NES_HEADER_SIZE, size)
+	}
 
-	characterRomStart := NES_HEADER_SIZE + rom.ProgramRomPages*PROGRAM_ROM_SIZE
-	characterRomEnd := characterRomStart + rom.CharacterRomPages*CHARACTER_ROM_SIZE
-	fmt.Printf("Character ROM start: 0x%x (%d)\n", characterRomStart, characterRomStart)
-	fmt.Printf("Character ROM end: 0x%x (%d)\n", characterRomEnd, characterRomEnd)
+	rom := new(NesRom)
+	rom.Header = *ParseHeader(buffer)
+	if rom.ProgramRomSize == 0 {
+		return nil, ErrEmptyProgram
+	}
 
-	rom.Program = buffer[NES_HEADER_SIZE:characterRomStart]
-	rom.Character = buffer[characterRomStart : characterRomStart+(characterRomEnd-characterRomStart)]
-	if len(rom.Character) == 0 {
-		rom.Character = make([]byte, 0xff)
+	programRomStart := uint(NES_HEADER_SIZE)
+	if rom.HasTrainer {
+		if size < programRomStart+TRAINER_SIZE {
+			return nil, truncated(ErrTruncatedTrainer, programRomStart+TRAINER_SIZE, size)
+		}
+		rom.Trainer = buffer[programRomStart : programRomStart+TRAINER_SIZE]
+		programRomStart += TRAINER_SIZE
 	}
+	characterRomStart := programRomStart + rom.ProgramRomSize
+	characterRomEnd := characterRomStart + rom.CharacterRomSize
+	if size < characterRomStart {
+		return nil, truncated(ErrTruncatedProgram, characterRomStart, size)
+	}
+	if size < characterRomEnd {
+		return nil, truncated(ErrTruncatedCharacter, characterRomEnd, size)
+	}
+
+	rom.Program = buffer[programRomStart:characterRomStart]
+	rom.Character = buffer[characterRomStart:characterRomEnd]
+	return rom, nil
+}
 
+func (N *NesRom) Dump() {
+	fmt.Printf("CRC32: %08X SHA-1: %s\n", N.Crc32, N.Sha1)
+	if N.Game != nil {
+		fmt.Printf("Game: %s (%s), found in database\n", N.Game.Title, N.Game.Region)
+	}
+	fmt.Printf("Format: %s\n", FormatName[N.Format])
+	if N.Board != "" {
+		fmt.Printf("Board: %s\n", N.Board)
+	}
+	if N.Patch != "" {
+		fmt.Printf("Patch: %s\n", N.Patch)
+	}
+	if N.HasGarbage {
+		fmt.Printf("Header bytes 7-15 contain garbage, ignored\n")
+	}
+	fmt.Printf("Program ROM pages: %d\n", N.ProgramRomPages)
+	fmt.Printf("Character ROM pages: %d\n", N.CharacterRomPages)
+	fmt.Printf("Mapper: %d.%d\n", N.Mapper, N.SubMapper)
+	fmt.Printf("Program RAM: %d bytes, NVRAM: %d bytes\n", N.ProgramRamSize, N.ProgramNvramSize)
+	fmt.Printf("Character RAM: %d bytes, NVRAM: %d bytes\n", N.CharacterRamSize, N.CharacterNvramSize)
+	fmt.Printf("Console: %s, TV system: %s\n", ConsoleTypeName[N.ConsoleType], TvSystemName[N.TvSystem])
 	fmt.Printf(
 		"Program   ROM: 0x0000 - 0x%x (%d bytes)\n",
-		len(rom.Program)-1,
-		len(rom.Program))
-	fmt.Printf(
-		"Character   ROM: 0x0000 - 0x%x (%d bytes)\n",
-		len(rom.Character)-1,
-		len(rom.Character))
-
-	return rom
+		len(N.Program)-1,
+		len(N.Program))
+	if len(N.Character) > 0 {
+		fmt.Printf(
+			"Character   ROM: 0x0000 - 0x%x (%d bytes)\n",
+			len(N.Character)-1,
+			len(N.Character))
+	}
 }
@@ -0,0 +1,56 @@
+package reader
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	header := []byte{'N', 'E', 'S', 0x1A, 2, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	trainer := []byte{'N', 'E', 'S', 0x1A, 2, 1, 0x04, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	noProgram := []byte{'N', 'E', 'S', 0x1A, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	unif := make([]byte, UNIF_HEADER_SIZE)
+	copy(unif, UNIF_MAGIC)
+	unifNoProgram := append(unif, unifChunk("MAPR", []byte("NES-NROM-256\x00"))...)
+	unifNoProgram = append(unifNoProgram, unifChunk("CHR0", make([]byte, CHARACTER_ROM_SIZE))...)
+
+	tests := []struct {
+		name  string
+		image []byte
+		err   error
+	}{
+		{"bad magic", []byte("XYZ"), ErrBadMagic},
+		{"truncated header", header[:10], ErrTruncatedHeader},
+		{"truncated trainer", append(trainer, make([]byte, 0x100)...), ErrTruncatedTrainer},
+		{"truncated PRG ROM", append(header, make([]byte, 0x5000)...), ErrTruncatedProgram},
+		{"truncated CHR ROM", append(header, make([]byte, 0x9000)...), ErrTruncatedCharacter},
+		{"no PRG ROM", append(noProgram, make([]byte, CHARACTER_ROM_SIZE)...), ErrEmptyProgram},
+		{"truncated UNIF header", unif[:16], ErrTruncatedHeader},
+		{"UNIF without PRG ROM", unifNoProgram, ErrEmptyProgram},
+	}
+	for _, test := range tests {
+		if _, err := Parse(test.image); !errors.Is(err, test.err) {
+			t.Errorf("%s: %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	image := []byte{'N', 'E', 'S', 0x1A, 2, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	image = append(image, make([]byte, 2*PROGRAM_ROM_SIZE+CHARACTER_ROM_SIZE)...)
+	rom, err := Read(bytes.NewReader(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rom.Program) != 2*PROGRAM_ROM_SIZE || len(rom.Character) != CHARACTER_ROM_SIZE {
+		t.Errorf("PRG %d bytes, CHR %d bytes", len(rom.Program), len(rom.Character))
+	}
+}
+
+// unifChunk encodes a UNIF chunk: ID, little endian length, data.
+func unifChunk(id string, data []byte) []byte {
+	chunk := []byte(id)
+	chunk = append(chunk, byte(len(data)), byte(len(data)>>8), byte(len(data)>>16), byte(len(data)>>24))
+	return append(chunk, data...)
+}
@@ -0,0 +1,325 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var PatchExtensions = []string{".ips", ".ups", ".bps"}
+
+// Sizes read from UPS/BPS patches are checked before allocating, no cartridge is bigger.
+const MAX_PATCH_TARGET_SIZE = 0x4000000
+
+var (
+	ErrUnknownPatch     = errors.New("unknown patch format")
+	ErrCorruptPatch     = errors.New("corrupt patch")
+	ErrPatchChecksum    = errors.New("patch checksum mismatch")
+	ErrSourceChecksum   = errors.New("source checksum mismatch, patch is made for another ROM")
+	ErrTargetChecksum   = errors.New("target checksum mismatch")
+	ErrSourceSizeDiffer = errors.New("source size mismatch, patch is made for another ROM")
+)
+
+// FindPatch returns a .ips/.ups/.bps file with the same base name as the ROM, or "" when there is none.
+func FindPatch(file string) string {
+	base := strings.TrimSuffix(file, filepath.Ext(file))
+	for _, ext := range PatchExtensions {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return ""
+}
+
+func ApplyPatchFile(image []byte, file string) ([]byte, error) {
+	patch, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyPatch(image, patch)
+}
+
+// ApplyPatch detects the patch format by its signature. The image is not modified.
+func ApplyPatch(image []byte, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, []byte("PATCH")):
+		return ApplyIps(image, patch)
+	case bytes.HasPrefix(patch, []byte("UPS1")):
+		return ApplyUps(image, patch)
+	case bytes.HasPrefix(patch, []byte("BPS1")):
+		return ApplyBps(image, patch)
+	}
+	return nil, ErrUnknownPatch
+}
+
+// IPS: "PATCH", records, "EOF" [, 3 byte truncate size]
+// record: 3 byte offset, 2 byte size, data. Size 0 is RLE: 2 byte count, 1 byte value.
+// All numbers are big endian.
+func ApplyIps(image []byte, patch []byte) ([]byte, error) {
+	target := append([]byte{}, image...)
+	p := patchReader{data: patch, pos: 5}
+	for {
+		if p.remain() < 3 {
+			return nil, ErrCorruptPatch
+		}
+		if bytes.Equal(patch[p.pos:p.pos+3], []byte("EOF")) {
+			p.pos += 3
+			break
+		}
+		offset := p.uint24()
+		size := p.uint16()
+		if size > 0 {
+			data := p.bytes(size)
+			if p.err != nil {
+				return nil, p.err
+			}
+			target = grow(target, offset+size)
+			copy(target[offset:], data)
+			continue
+		}
+		count := p.uint16()
+		value := p.byte()
+		if p.err != nil {
+			return nil, p.err
+		}
+		target = grow(target, offset+count)
+		for i := uint(0); i < count; i++ {
+			target[offset+i] = value
+		}
+	}
+	if p.remain() >= 3 {
+		if size := p.uint24(); size < uint(len(target)) {
+			target = target[:size]
+		}
+	}
+	return target, nil
+}
+
+// UPS: "UPS1", source size, target size, hunks, source CRC32, target CRC32, patch CRC32
+// hunk: relative offset, XOR data terminated by 0x00.
+// Sizes and offsets are variable length numbers, CRCs are little endian.
+func ApplyUps(image []byte, patch []byte) ([]byte, error) {
+	if err := verifyPatch(patch); err != nil {
+		return nil, err
+	}
+	p := patchReader{data: patch[:len(patch)-12], pos: 4}
+	sourceSize := p.varint()
+	targetSize := p.varint()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if sourceSize != uint(len(image)) {
+		return nil, ErrSourceSizeDiffer
+	}
+	if crc32.ChecksumIEEE(image) != footer(patch, 0) {
+		return nil, ErrSourceChecksum
+	}
+	if targetSize > MAX_PATCH_TARGET_SIZE {
+		return nil, fmt.Errorf("%w: target size %d", ErrCorruptPatch, targetSize)
+	}
+
+	target := make([]byte, targetSize)
+	copy(target, image)
+	offset := uint(0)
+	for p.remain() > 0 {
+		offset += p.varint()
+		for {
+			x := p.byte()
+			if p.err != nil {
+				return nil, p.err
+			}
+			if offset < targetSize {
+				target[offset] ^= x
+			}
+			offset++
+			if x == 0 {
+				break
+			}
+		}
+	}
+	if crc32.ChecksumIEEE(target) != footer(patch, 4) {
+		return nil, ErrTargetChecksum
+	}
+	return target, nil
+}
+
+// BPS: "BPS1", source size, target size, metadata size, metadata, actions,
+// source CRC32, target CRC32, patch CRC32
+// action: (length-1) << 2 | command
+//
+//	0: SourceRead  copy from source at the output offset
+//	1: TargetRead  copy from the patch
+//	2: SourceCopy  copy from source at a relative offset
+//	3: TargetCopy  copy from already written target at a relative offset
+func ApplyBps(image []byte, patch []byte) ([]byte, error) {
+	if err := verifyPatch(patch); err != nil {
+		return nil, err
+	}
+	p := patchReader{data: patch[:len(patch)-12], pos: 4}
+	sourceSize := p.varint()
+	targetSize := p.varint()
+	p.bytes(p.varint())
+	if p.err != nil {
+		return nil, p.err
+	}
+	if sourceSize != uint(len(image)) {
+		return nil, ErrSourceSizeDiffer
+	}
+	if crc32.ChecksumIEEE(image) != footer(patch, 0) {
+		return nil, ErrSourceChecksum
+	}
+	if targetSize > MAX_PATCH_TARGET_SIZE {
+		return nil, fmt.Errorf("%w: target size %d", ErrCorruptPatch, targetSize)
+	}
+
+	target := make([]byte, targetSize)
+	var output, sourceOffset, targetOffset int
+	for p.remain() > 0 {
+		action := p.varint()
+		if p.err != nil {
+			return nil, p.err
+		}
+		if action>>2 >= uint(len(target)-output) {
+			return nil, ErrCorruptPatch
+		}
+		length := int(action>>2) + 1
+		switch action & 0x03 {
+		case 0:
+			if output+length > len(image) {
+				return nil, ErrCorruptPatch
+			}
+			copy(target[output:], image[output:output+length])
+		case 1:
+			copy(target[output:], p.bytes(uint(length)))
+		case 2:
+			sourceOffset += p.signedVarint()
+			if sourceOffset < 0 || sourceOffset > len(image)-length {
+				return nil, ErrCorruptPatch
+			}
+			copy(target[output:], image[sourceOffset:sourceOffset+length])
+			sourceOffset += length
+		case 3:
+			targetOffset += p.signedVarint()
+			if targetOffset < 0 || targetOffset >= output {
+				return nil, ErrCorruptPatch
+			}
+			// NOTE: Source and destination may overlap, byte by byte copy repeats the pattern.
+			for i := 0; i < length; i++ {
+				target[output+i] = target[targetOffset]
+				targetOffset++
+			}
+		}
+		if p.err != nil {
+			return nil, p.err
+		}
+		output += length
+	}
+	if crc32.ChecksumIEEE(target) != footer(patch, 4) {
+		return nil, ErrTargetChecksum
+	}
+	return target, nil
+}
+
+func verifyPatch(patch []byte) error {
+	if len(patch) < 16 {
+		return ErrCorruptPatch
+	}
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != footer(patch, 8) {
+		return ErrPatchChecksum
+	}
+	return nil
+}
+
+// CRC32 stored in the 12 byte footer of UPS/BPS patches.
+func footer(patch []byte, offset int) uint32 {
+	return binary.LittleEndian.Uint32(patch[len(patch)-12+offset:])
+}
+
+func grow(data []byte, size uint) []byte {
+	if uint(len(data)) >= size {
+		return data
+	}
+	return append(data, make([]byte, size-uint(len(data)))...)
+}
+
+type patchReader struct {
+	data []byte
+	pos  uint
+	err  error
+}
+
+func (P *patchReader) remain() uint {
+	return uint(len(P.data)) - P.pos
+}
+
+// bytes returns nil past the end of the data, the error is kept until the caller checks it.
+func (P *patchReader) bytes(size uint) []byte {
+	if P.err == nil && P.remain() < size {
+		P.err = fmt.Errorf("%w: unexpected end of data", ErrCorruptPatch)
+	}
+	if P.err != nil {
+		return nil
+	}
+	data := P.data[P.pos : P.pos+size]
+	P.pos += size
+	return data
+}
+
+func (P *patchReader) byte() byte {
+	return byte(P.number(1))
+}
+
+func (P *patchReader) uint16() uint {
+	return P.number(2)
+}
+
+func (P *patchReader) uint24() uint {
+	return P.number(3)
+}
+
+// Big endian number of IPS, 0 past the end of the data.
+func (P *patchReader) number(size uint) uint {
+	var value uint = 0
+	for _, x := range P.bytes(size) {
+		value = value<<8 | uint(x)
+	}
+	return value
+}
+
+// Longer variable length numbers don't fit in 56 bits, no valid patch has them.
+const MAX_VARINT_BYTES = 8
+
+// Variable length number of UPS/BPS, each byte carries 7 bits and bit 7 marks the last one.
+func (P *patchReader) varint() uint {
+	var value uint = 0
+	var shift uint = 1
+	for i := 0; P.err == nil; i++ {
+		if i == MAX_VARINT_BYTES {
+			P.err = fmt.Errorf("%w: number too long", ErrCorruptPatch)
+			break
+		}
+		x := P.byte()
+		value += uint(x&0x7F) * shift
+		if x&0x80 > 0 {
+			break
+		}
+		shift <<= 7
+		value += shift
+	}
+	return value
+}
+
+// Relative offset of BPS copy commands, bit 0 is the sign.
+func (P *patchReader) signedVarint() int {
+	data := P.varint()
+	if data&0x01 > 0 {
+		return -int(data >> 1)
+	}
+	return int(data >> 1)
+}
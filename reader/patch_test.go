@@ -0,0 +1,156 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// varint encodes a UPS/BPS variable length number.
+func varint(value uint) []byte {
+	var data []byte
+	for {
+		x := byte(value & 0x7F)
+		value >>= 7
+		if value == 0 {
+			return append(data, 0x80|x)
+		}
+		data = append(data, x)
+		value--
+	}
+}
+
+// patchFooter appends the source, target and patch CRC32s of UPS/BPS patches.
+func patchFooter(patch []byte, source []byte, target []byte) []byte {
+	crc := make([]byte, 4)
+	binary.LittleEndian.PutUint32(crc, crc32.ChecksumIEEE(source))
+	patch = append(patch, crc...)
+	binary.LittleEndian.PutUint32(crc, crc32.ChecksumIEEE(target))
+	patch = append(patch, crc...)
+	binary.LittleEndian.PutUint32(crc, crc32.ChecksumIEEE(patch))
+	return append(patch, crc...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var data []byte
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
+
+type patchTest struct {
+	name   string
+	source []byte
+	patch  []byte
+	target []byte
+	err    error
+}
+
+func runPatchTests(t *testing.T, tests []patchTest) {
+	for _, test := range tests {
+		source := append([]byte{}, test.source...)
+		target, err := ApplyPatch(source, test.patch)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: error %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if !bytes.Equal(target, test.target) {
+			t.Errorf("%s: %q, want %q", test.name, target, test.target)
+		}
+		if !bytes.Equal(source, test.source) {
+			t.Errorf("%s: source modified", test.name)
+		}
+	}
+}
+
+func TestApplyIps(t *testing.T) {
+	source := []byte("hello world")
+	record := []byte{0x00, 0x00, 0x01, 0x00, 0x02, 'E', 'Y'}
+	rle := []byte{0x00, 0x00, 0x0C, 0x00, 0x00, 0x00, 0x03, '!'}
+
+	runPatchTests(t, []patchTest{
+		{"record", source, concat([]byte("PATCH"), record, []byte("EOF")), []byte("hEYlo world"), nil},
+		{"RLE past the end", source, concat([]byte("PATCH"), record, rle, []byte("EOF")), []byte("hEYlo world\x00!!!"), nil},
+		{"truncate", source, concat([]byte("PATCH"), record, []byte("EOF"), []byte{0x00, 0x00, 0x04}), []byte("hEYl"), nil},
+		{"truncate bigger than the target", source, concat([]byte("PATCH"), []byte("EOF"), []byte{0x00, 0x01, 0x00}), source, nil},
+		{"no EOF", source, []byte("PATCH\x00\x00"), nil, ErrCorruptPatch},
+		{"truncated record", source, concat([]byte("PATCH"), []byte{0x00, 0x00, 0x01, 0x00, 0x05, 'a', 'b'}), nil, ErrCorruptPatch},
+		{"truncated RLE", source, concat([]byte("PATCH"), []byte{0x00, 0x00, 0x01, 0x00, 0x00, 0x00}), nil, ErrCorruptPatch},
+		{"unknown format", source, []byte("PATCX"), nil, ErrUnknownPatch},
+	})
+}
+
+func TestApplyUps(t *testing.T) {
+	source := []byte("hello world")
+	target := []byte("hellO world!!")
+	hunks := concat(
+		varint(4), []byte{'o' ^ 'O', 0x00},
+		varint(5), []byte{'!', '!', 0x00},
+	)
+	patch := patchFooter(concat([]byte("UPS1"), varint(uint(len(source))), varint(uint(len(target))), hunks), source, target)
+
+	corrupt := append([]byte{}, patch...)
+	corrupt[len(corrupt)-13] ^= 0x01
+	badTarget := patchFooter(concat([]byte("UPS1"), varint(uint(len(source))), varint(uint(len(target))), hunks), source, source)
+	hugeTarget := patchFooter(concat([]byte("UPS1"), varint(uint(len(source))), varint(1<<40)), source, target)
+	longNumber := patchFooter(concat([]byte("UPS1"), bytes.Repeat([]byte{0x7F}, 16)), source, target)
+	truncated := patchFooter(concat([]byte("UPS1"), varint(uint(len(source))), varint(uint(len(target))), varint(4), []byte{0x20}), source, target)
+
+	runPatchTests(t, []patchTest{
+		{"round trip", source, patch, target, nil},
+		{"patch checksum", source, corrupt, nil, ErrPatchChecksum},
+		{"source checksum", []byte("hello worle"), patch, nil, ErrSourceChecksum},
+		{"source size", []byte("hello"), patch, nil, ErrSourceSizeDiffer},
+		{"target checksum", source, badTarget, nil, ErrTargetChecksum},
+		{"huge target size", source, hugeTarget, nil, ErrCorruptPatch},
+		{"number too long", source, longNumber, nil, ErrCorruptPatch},
+		{"unterminated hunk", source, truncated, nil, ErrCorruptPatch},
+		{"too short", source, []byte("UPS1"), nil, ErrCorruptPatch},
+	})
+}
+
+func TestApplyBps(t *testing.T) {
+	source := []byte("abcdefgh")
+	target := []byte("abcXYXYXYfgh-ab")
+	header := concat([]byte("BPS1"), varint(uint(len(source))), varint(uint(len(target))), varint(2), []byte("md"))
+	actions := concat(
+		// SourceRead "abc"
+		varint((3-1)<<2|0),
+		// TargetRead "XY"
+		varint((2-1)<<2|1), []byte("XY"),
+		// TargetCopy "XYXY" from 3, overlapping
+		varint((4-1)<<2|3), varint(3<<1),
+		// SourceCopy "fgh" from 5
+		varint((3-1)<<2|2), varint(5<<1),
+		// TargetRead "-"
+		varint((1-1)<<2|1), []byte("-"),
+		// SourceCopy "ab" from 0, 8 back
+		varint((2-1)<<2|2), varint(8<<1|1),
+	)
+	patch := patchFooter(concat(header, actions), source, target)
+
+	corrupt := append([]byte{}, patch...)
+	corrupt[len(corrupt)-13] ^= 0x01
+	badTarget := patchFooter(concat(header, actions), source, source)
+	hugeTarget := patchFooter(concat([]byte("BPS1"), varint(uint(len(source))), varint(1<<40), varint(0)), source, target)
+	hugeMetadata := patchFooter(concat([]byte("BPS1"), varint(uint(len(source))), varint(uint(len(target))), varint(1<<40)), source, target)
+	longAction := patchFooter(concat(header, varint(uint(len(target))<<2|1)), source, target)
+	badCopy := patchFooter(concat(header, varint((2-1)<<2|2), varint(7<<1)), source, target)
+	badTargetCopy := patchFooter(concat(header, varint((2-1)<<2|3), varint(0)), source, target)
+
+	runPatchTests(t, []patchTest{
+		{"round trip", source, patch, target, nil},
+		{"patch checksum", source, corrupt, nil, ErrPatchChecksum},
+		{"source checksum", []byte("abcdefgi"), patch, nil, ErrSourceChecksum},
+		{"source size", source[:7], patch, nil, ErrSourceSizeDiffer},
+		{"target checksum", source, badTarget, nil, ErrTargetChecksum},
+		{"huge target size", source, hugeTarget, nil, ErrCorruptPatch},
+		{"huge metadata", source, hugeMetadata, nil, ErrCorruptPatch},
+		{"action past the target", source, longAction, nil, ErrCorruptPatch},
+		{"SourceCopy past the source", source, badCopy, nil, ErrCorruptPatch},
+		{"TargetCopy of unwritten data", source, badTargetCopy, nil, ErrCorruptPatch},
+	})
+}
@@ -0,0 +1,221 @@
+package reader
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+/*
+	UNIF
+	see. https://wiki.nesdev.com/w/index.php/UNIF
+
+	The 32 byte header ("UNIF", revision, padding) is followed by chunks:
+	4 byte ID, 4 byte little endian length, data.
+
+	| chunk     | description                                              |
+	+-----------+----------------------------------------------------------+
+	| MAPR      | board name, null terminated                              |
+	| PRG0-PRGF | PRG ROM, concatenated in hex order                       |
+	| CHR0-CHRF | CHR ROM, concatenated in hex order                       |
+	| MIRR      | 0: horizontal, 1: vertical, 2,3: one-screen A/B,         |
+	|           | 4: four-screen, 5: mapper controlled                     |
+	| BATR      | battery present                                          |
+	| TVCI      | 0: NTSC, 1: PAL, 2: both                                 |
+	| NAME      | game name, null terminated                               |
+*/
+
+const UNIF_MAGIC = "UNIF"
+const UNIF_HEADER_SIZE = 0x0020
+const UNIF_CHUNK_HEADER_SIZE = 0x0008
+
+type unifBoard struct {
+	mapper uint
+	// PRG-RAM at 0x6000-0x7FFF, battery backed when BATR is set.
+	programRamSize uint
+}
+
+// Board names without the NES-/HVC-/UNL-/BTL-/BMC- prefix.
+var unifBoards = map[string]unifBoard{
+	"NROM":               {0, 0},
+	"NROM-128":           {0, 0},
+	"NROM-256":           {0, 0},
+	"HROM":               {0, 0},
+	"RROM":               {0, 0},
+	"SAROM":              {1, PROGRAM_RAM_SIZE},
+	"SBROM":              {1, 0},
+	"SCROM":              {1, 0},
+	"SEROM":              {1, 0},
+	"SFROM":              {1, 0},
+	"SGROM":              {1, 0},
+	"SHROM":              {1, 0},
+	"SJROM":              {1, PROGRAM_RAM_SIZE},
+	"SKROM":              {1, PROGRAM_RAM_SIZE},
+	"SLROM":              {1, 0},
+	"SL1ROM":             {1, 0},
+	"SNROM":              {1, PROGRAM_RAM_SIZE},
+	"SOROM":              {1, 0x4000},
+	"SUROM":              {1, PROGRAM_RAM_SIZE},
+	"SXROM":              {1, 0x8000},
+	"UNROM":              {2, 0},
+	"UOROM":              {2, 0},
+	"CNROM":              {3, 0},
+	"TBROM":              {4, 0},
+	"TEROM":              {4, 0},
+	"TFROM":              {4, 0},
+	"TGROM":              {4, 0},
+	"TKROM":              {4, PROGRAM_RAM_SIZE},
+	"TLROM":              {4, 0},
+	"TL1ROM":             {4, 0},
+	"TNROM":              {4, PROGRAM_RAM_SIZE},
+	"TSROM":              {4, PROGRAM_RAM_SIZE},
+	"TR1ROM":             {4, 0},
+	"ANROM":              {7, 0},
+	"AN1ROM":             {7, 0},
+	"AMROM":              {7, 0},
+	"AOROM":              {7, 0},
+	"COLORDREAMS-74*377": {11, 0},
+	"GNROM":              {66, 0},
+	"MHROM":              {66, 0},
+}
+
+var unifBoardPrefixes = []string{"NES-", "HVC-", "UNL-", "BTL-", "BMC-"}
+
+// Mappers selecting the mirroring with their registers, the only ones MIRR 5 is valid for.
+var unifMapperMirroring = map[uint]bool{
+	1: true,
+	4: true,
+	7: true,
+}
+
+func parseUnif(buffer []byte) (*NesRom, error) {
+	size := uint(len(buffer))
+	if size < UNIF_HEADER_SIZE {
+		return nil, truncated(ErrTruncatedHeader, UNIF_HEADER_SIZE, size)
+	}
+
+	rom := new(NesRom)
+	rom.Format = FormatUnif
+
+	var mirroring byte = 0
+	var programs, characters [16][]byte
+	for offset := uint(UNIF_HEADER_SIZE); offset < size; {
+		if size < offset+UNIF_CHUNK_HEADER_SIZE {
+			return nil, truncated(ErrTruncatedChunk, offset+UNIF_CHUNK_HEADER_SIZE, size)
+		}
+		id := string(buffer[offset : offset+4])
+		length := uint(binary.LittleEndian.Uint32(buffer[offset+4 : offset+8]))
+		offset += UNIF_CHUNK_HEADER_SIZE
+		if size < offset+length {
+			return nil, truncated(ErrTruncatedChunk, offset+length, size)
+		}
+		data := buffer[offset : offset+length]
+		offset += length
+
+		switch {
+		case id == "MAPR":
+			rom.Board = cString(data)
+		case strings.HasPrefix(id, "PRG"):
+			if i, ok := hexDigit(id[3]); ok {
+				programs[i] = data
+			}
+		case strings.HasPrefix(id, "CHR"):
+			if i, ok := hexDigit(id[3]); ok {
+				characters[i] = data
+			}
+		case id == "MIRR" && length > 0:
+			mirroring = data[0]
+		case id == "BATR":
+			rom.Battery = length == 0 || data[0] != 0
+		case id == "TVCI" && length > 0:
+			switch data[0] {
+			case 1:
+				rom.TvSystem = TvSystemPal
+			case 2:
+				rom.TvSystem = TvSystemMultiRegion
+			}
+		}
+	}
+
+	board, ok := unifBoardOf(rom.Board)
+	if !ok {
+		return nil, &UnsupportedBoardError{rom.Board}
+	}
+	rom.Mapper = board.mapper
+
+	switch mirroring {
+	case 0:
+		rom.HorizontalMirror = true
+	case 1:
+		rom.HorizontalMirror = false
+	case 2, 3:
+		rom.SingleScreen = true
+		rom.SingleScreenPage = uint(mirroring - 2)
+	case 4:
+		rom.FourScreen = true
+	case 5:
+		// NOTE: The mapper sets the mirroring at power-on, the header value is only a placeholder.
+		if !unifMapperMirroring[board.mapper] {
+			return nil, &UnsupportedMirroringError{rom.Board, mirroring}
+		}
+		rom.HorizontalMirror = true
+	default:
+		return nil, &UnsupportedMirroringError{rom.Board, mirroring}
+	}
+
+	// NOTE: Chunks are usually not adjacent, so the banks are copied into a new buffer.
+	rom.Program = concatChunks(programs)
+	rom.Character = concatChunks(characters)
+	if len(rom.Program) == 0 {
+		return nil, ErrEmptyProgram
+	}
+
+	rom.ProgramRomSize = uint(len(rom.Program))
+	rom.CharacterRomSize = uint(len(rom.Character))
+	rom.ProgramRomPages = rom.ProgramRomSize / PROGRAM_ROM_SIZE
+	rom.CharacterRomPages = rom.CharacterRomSize / CHARACTER_ROM_SIZE
+	if rom.Battery {
+		rom.ProgramNvramSize = board.programRamSize
+	} else {
+		rom.ProgramRamSize = board.programRamSize
+	}
+	if rom.CharacterRomSize == 0 {
+		rom.CharacterRamSize = CHARACTER_ROM_SIZE
+	}
+	return rom, nil
+}
+
+func unifBoardOf(name string) (unifBoard, bool) {
+	name = strings.ToUpper(name)
+	for _, prefix := range unifBoardPrefixes {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	board, ok := unifBoards[name]
+	return board, ok
+}
+
+func concatChunks(chunks [16][]byte) []byte {
+	var buffer []byte
+	for _, chunk := range chunks {
+		buffer = append(buffer, chunk...)
+	}
+	return buffer
+}
+
+func cString(data []byte) string {
+	for i, b := range data {
+		if b == 0 {
+			return string(data[:i])
+		}
+	}
+	return string(data)
+}
+
+func hexDigit(c byte) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), true
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,148 @@
+package reader
+
+import (
+	"errors"
+	"testing"
+)
+
+func newUnif(chunks ...[]byte) []byte {
+	image := make([]byte, UNIF_HEADER_SIZE)
+	copy(image, UNIF_MAGIC)
+	for _, chunk := range chunks {
+		image = append(image, chunk...)
+	}
+	return image
+}
+
+func TestParseUnif(t *testing.T) {
+	program0 := make([]byte, PROGRAM_ROM_SIZE)
+	program0[0] = 0xAA
+	program1 := make([]byte, PROGRAM_ROM_SIZE)
+	program1[0] = 0xBB
+	image := newUnif(
+		unifChunk("MAPR", []byte("NES-SNROM\x00")),
+		unifChunk("NAME", []byte("Test\x00")),
+		// Banks are ordered by their number, not by the chunk order.
+		unifChunk("PRG1", program1),
+		unifChunk("PRG0", program0),
+		unifChunk("MIRR", []byte{1}),
+		unifChunk("BATR", []byte{1}),
+		unifChunk("TVCI", []byte{1}),
+	)
+	rom, err := Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rom.Format != FormatUnif || rom.Board != "NES-SNROM" || rom.Mapper != 1 {
+		t.Errorf("format %s, board %s, mapper %d", FormatName[rom.Format], rom.Board, rom.Mapper)
+	}
+	if len(rom.Program) != 2*PROGRAM_ROM_SIZE || rom.Program[0] != 0xAA || rom.Program[PROGRAM_ROM_SIZE] != 0xBB {
+		t.Errorf("PRG ROM %d bytes, banks %02X %02X", len(rom.Program), rom.Program[0], rom.Program[PROGRAM_ROM_SIZE])
+	}
+	if rom.ProgramRomPages != 2 || rom.CharacterRomSize != 0 || rom.CharacterRamSize != CHARACTER_ROM_SIZE {
+		t.Errorf("PRG pages %d, CHR ROM %d, CHR RAM %d", rom.ProgramRomPages, rom.CharacterRomSize, rom.CharacterRamSize)
+	}
+	if !rom.Battery || rom.ProgramNvramSize != PROGRAM_RAM_SIZE || rom.ProgramRamSize != 0 {
+		t.Errorf("battery %t, RAM %d, NVRAM %d", rom.Battery, rom.ProgramRamSize, rom.ProgramNvramSize)
+	}
+	if rom.HorizontalMirror || rom.TvSystem != TvSystemPal {
+		t.Errorf("horizontal %t, TV system %s", rom.HorizontalMirror, TvSystemName[rom.TvSystem])
+	}
+}
+
+func TestParseUnifBoards(t *testing.T) {
+	tests := []struct {
+		board   string
+		battery bool
+		mapper  uint
+		ram     uint
+		nvram   uint
+	}{
+		{"NES-NROM-256", false, 0, 0, 0},
+		{"NES-SNROM", false, 1, PROGRAM_RAM_SIZE, 0},
+		{"NES-SOROM", true, 1, 0, 0x4000},
+		{"HVC-SXROM", false, 1, 0x8000, 0},
+		{"NES-TKROM", true, 4, 0, PROGRAM_RAM_SIZE},
+		{"NES-TLROM", false, 4, 0, 0},
+		{"UNL-COLORDREAMS-74*377", false, 11, 0, 0},
+	}
+	for _, test := range tests {
+		chunks := [][]byte{
+			unifChunk("MAPR", []byte(test.board)),
+			unifChunk("PRG0", make([]byte, PROGRAM_ROM_SIZE)),
+		}
+		if test.battery {
+			chunks = append(chunks, unifChunk("BATR", []byte{1}))
+		}
+		rom, err := Parse(newUnif(chunks...))
+		if err != nil {
+			t.Errorf("%s: %v", test.board, err)
+			continue
+		}
+		if rom.Mapper != test.mapper || rom.ProgramRamSize != test.ram || rom.ProgramNvramSize != test.nvram {
+			t.Errorf("%s: mapper %d, RAM %d, NVRAM %d", test.board, rom.Mapper, rom.ProgramRamSize, rom.ProgramNvramSize)
+		}
+	}
+}
+
+func TestParseUnifMirroring(t *testing.T) {
+	tests := []struct {
+		board      string
+		mirroring  []byte
+		horizontal bool
+		four       bool
+		single     bool
+		page       uint
+	}{
+		{"NES-NROM-256", nil, true, false, false, 0},
+		{"NES-NROM-256", []byte{0}, true, false, false, 0},
+		{"NES-NROM-256", []byte{1}, false, false, false, 0},
+		{"NES-NROM-256", []byte{2}, false, false, true, 0},
+		{"NES-NROM-256", []byte{3}, false, false, true, 1},
+		{"NES-NROM-256", []byte{4}, false, true, false, 0},
+		{"NES-AOROM", []byte{5}, true, false, false, 0},
+		{"NES-TLROM", []byte{5}, true, false, false, 0},
+	}
+	for _, test := range tests {
+		chunks := [][]byte{
+			unifChunk("MAPR", []byte(test.board)),
+			unifChunk("PRG0", make([]byte, PROGRAM_ROM_SIZE)),
+		}
+		if test.mirroring != nil {
+			chunks = append(chunks, unifChunk("MIRR", test.mirroring))
+		}
+		rom, err := Parse(newUnif(chunks...))
+		if err != nil {
+			t.Errorf("%s MIRR %v: %v", test.board, test.mirroring, err)
+			continue
+		}
+		if rom.HorizontalMirror != test.horizontal || rom.FourScreen != test.four ||
+			rom.SingleScreen != test.single || rom.SingleScreenPage != test.page {
+			t.Errorf("%s MIRR %v: horizontal %t, four-screen %t, single-screen %t page %d", test.board, test.mirroring,
+				rom.HorizontalMirror, rom.FourScreen, rom.SingleScreen, rom.SingleScreenPage)
+		}
+	}
+}
+
+func TestParseUnifErrors(t *testing.T) {
+	program := unifChunk("PRG0", make([]byte, PROGRAM_ROM_SIZE))
+	var boardError *UnsupportedBoardError
+	var mirroringError *UnsupportedMirroringError
+
+	if _, err := Parse(newUnif(unifChunk("MAPR", []byte("UNL-FOO")), program)); !errors.As(err, &boardError) || boardError.Board != "UNL-FOO" {
+		t.Errorf("unknown board: %v", err)
+	}
+	if _, err := Parse(newUnif(unifChunk("MAPR", []byte("NES-NROM")), program, unifChunk("MIRR", []byte{5}))); !errors.As(err, &mirroringError) {
+		t.Errorf("MIRR 5 on NROM: %v", err)
+	}
+	if _, err := Parse(newUnif(unifChunk("MAPR", []byte("NES-NROM")), program, unifChunk("MIRR", []byte{6}))); !errors.As(err, &mirroringError) {
+		t.Errorf("MIRR 6: %v", err)
+	}
+	truncated := newUnif(unifChunk("MAPR", []byte("NES-NROM")), program)
+	if _, err := Parse(truncated[:len(truncated)-1]); !errors.Is(err, ErrTruncatedChunk) {
+		t.Errorf("truncated chunk: %v", err)
+	}
+	if _, err := Parse(append(truncated, 'C', 'H', 'R')); !errors.Is(err, ErrTruncatedChunk) {
+		t.Errorf("truncated chunk header: %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/popsul/gones/testrom"
+	"os"
+	"path/filepath"
+)
+
+// runTest implements "gones test", it runs test ROMs headless and prints a result table.
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	timeout := flags.Duration("timeout", testrom.DEFAULT_TIMEOUT, "emulated time limit per ROM")
+	verbose := flags.Bool("v", false, "print the whole message of each ROM")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s test [options] <file>...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+
+	failed := 0
+	for _, file := range flags.Args() {
+		result, err := testrom.RunFile(file, *timeout)
+		if err != nil {
+			fmt.Printf("%-7s %-40s %s\n", "ERROR", filepath.Base(file), err)
+			failed++
+			continue
+		}
+		status := "PASS"
+		if result.IsTimeout {
+			status = "TIMEOUT"
+		} else if !result.Passed() {
+			status = "FAIL"
+		}
+		if !result.Passed() {
+			failed++
+		}
+		fmt.Printf("%-7s %-40s %s\n", status, filepath.Base(file), result.Summary())
+		if *verbose && result.Message != "" {
+			fmt.Println(result.Message)
+		}
+	}
+	fmt.Printf("%d/%d passed\n", flags.NArg()-failed, flags.NArg())
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
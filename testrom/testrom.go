@@ -0,0 +1,158 @@
+package testrom
+
+import (
+	"fmt"
+	"github.com/popsul/gones/common"
+	"github.com/popsul/gones/console"
+	"github.com/popsul/gones/reader"
+	"strings"
+	"time"
+)
+
+/*
+	Result protocol of blargg's test ROMs (and many others following it)
+	see. https://github.com/christopherpow/nes-test-roms/blob/master/instr_test-v5/readme.txt
+
+	| addr          | content                                                       |
+	+---------------+---------------------------------------------------------------+
+	| 0x6000        | status: 0x80 running, 0x81 reset needed, otherwise the result |
+	|               | (0x00 passed, other values are the error code)                |
+	| 0x6001-0x6003 | signature 0xDE 0xB0 0x61, the other bytes are valid once set  |
+	| 0x6004-       | message, zero terminated text                                 |
+
+	The ROM asks for a reset with 0x81, it has to be pressed after at least 100 msec.
+*/
+
+const (
+	STATUS_ADDR    = 0x6000
+	SIGNATURE_ADDR = 0x6001
+	MESSAGE_ADDR   = 0x6004
+	STATUS_RUNNING = 0x80
+	STATUS_RESET   = 0x81
+	// Longer messages are cut, a ROM that never writes the terminator still has a result.
+	MESSAGE_MAX_SIZE = 0x1000
+	RESET_DELAY      = 100 * time.Millisecond
+	DEFAULT_TIMEOUT  = 30 * time.Second
+)
+
+var signature = [...]byte{0xDE, 0xB0, 0x61}
+
+type Result struct {
+	Status  byte
+	Message string
+	// Emulated time until the result (or the timeout).
+	Elapsed    time.Duration
+	IsTimeout  bool
+	IsJammed   bool
+	JamMessage string
+}
+
+func (R *Result) Passed() bool {
+	return !R.IsTimeout && !R.IsJammed && R.Status == 0x00
+}
+
+// Summary is a one line description of the result, the first line of the message.
+func (R *Result) Summary() string {
+	if R.IsJammed {
+		return R.JamMessage
+	}
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(R.Message), "\n", 2)[0])
+	if R.IsTimeout {
+		return strings.TrimSpace("timeout " + line)
+	}
+	if R.Status != 0x00 {
+		return strings.TrimSpace(fmt.Sprintf("#%d %s", R.Status, line))
+	}
+	return line
+}
+
+// RunFile loads a ROM file (see. reader.Load) and runs it, see. Run
+func RunFile(file string, timeout time.Duration) (*Result, error) {
+	rom, err := reader.Load(file, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return Run(rom, timeout)
+}
+
+// Run runs the ROM headless until it reports a result, timeout is in emulated time.
+func Run(rom *reader.NesRom, timeout time.Duration) (*Result, error) {
+	nes, err := console.NewConsole(rom)
+	if err != nil {
+		return nil, err
+	}
+	result := new(Result)
+	limit := cycles(timeout)
+	var elapsed uint64 = 0
+	var resetAt uint64 = 0
+	for elapsed < limit {
+		elapsed += uint64(nes.Run())
+		if err := nes.Jammed(); err != nil {
+			result.IsJammed = true
+			result.JamMessage = err.Error()
+			break
+		}
+		// NOTE: The result is checked once per frame.
+		if nes.RenderingData() == nil {
+			continue
+		}
+		if resetAt > 0 {
+			if elapsed >= resetAt {
+				nes.Reset()
+				resetAt = 0
+			}
+			continue
+		}
+		if !hasSignature(nes) {
+			continue
+		}
+		status := nes.Peek(STATUS_ADDR)
+		if status == STATUS_RUNNING {
+			continue
+		}
+		if status == STATUS_RESET {
+			resetAt = elapsed + cycles(RESET_DELAY)
+			continue
+		}
+		result.Status = status
+		result.Message = readMessage(nes)
+		result.Elapsed = duration(elapsed)
+		return result, nil
+	}
+	result.IsTimeout = !result.IsJammed
+	result.Elapsed = duration(elapsed)
+	if hasSignature(nes) {
+		result.Status = nes.Peek(STATUS_ADDR)
+		result.Message = readMessage(nes)
+	}
+	return result, nil
+}
+
+func hasSignature(nes *console.Console) bool {
+	for i, b := range signature {
+		if nes.Peek(SIGNATURE_ADDR+uint(i)) != b {
+			return false
+		}
+	}
+	return true
+}
+
+func readMessage(nes *console.Console) string {
+	var message []byte
+	for addr := uint(MESSAGE_ADDR); addr < MESSAGE_ADDR+MESSAGE_MAX_SIZE; addr++ {
+		b := nes.Peek(addr)
+		if b == 0 {
+			break
+		}
+		message = append(message, b)
+	}
+	return string(message)
+}
+
+func cycles(d time.Duration) uint64 {
+	return uint64(d.Seconds() * float64(common.CpuClock))
+}
+
+func duration(cycles uint64) time.Duration {
+	return time.Duration(float64(cycles) / float64(common.CpuClock) * float64(time.Second))
+}
@@ -0,0 +1,139 @@
+package testrom
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/popsul/gones/reader"
+)
+
+// check runs a test ROM, it is skipped when the file does not exist.
+func check(t *testing.T, file string, timeout time.Duration) {
+	t.Helper()
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		t.Skipf("%s not found", file)
+	}
+	result, err := RunFile(file, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Passed() {
+		t.Errorf("%s: %s\n%s", file, result.Summary(), result.Message)
+	}
+}
+
+// TestRoms runs every ROM put in testdata (see. testdata/README.md).
+func TestRoms(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.nes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Skip("no test ROMs in testdata")
+	}
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			check(t, file, DEFAULT_TIMEOUT)
+		})
+	}
+}
+
+const PROGRAM_START = 0xC000
+
+// store returns LDA #data, STA addr for each byte of data.
+func store(addr uint, data ...byte) []byte {
+	var code []byte
+	for i, b := range data {
+		code = append(code, 0xA9, b, 0x8D, byte(addr+uint(i)), byte((addr+uint(i))>>8))
+	}
+	return code
+}
+
+// report returns the code writing the signature, the message and then the status.
+func report(status byte, message string) []byte {
+	code := store(SIGNATURE_ADDR, signature[:]...)
+	code = append(code, store(MESSAGE_ADDR, append([]byte(message), 0)...)...)
+	return append(code, store(STATUS_ADDR, status)...)
+}
+
+func jumpToSelf(addr uint) []byte {
+	return []byte{0x4C, byte(addr), byte(addr >> 8)}
+}
+
+func concat(parts ...[]byte) []byte {
+	var data []byte
+	for _, part := range parts {
+		data = append(data, part...)
+	}
+	return data
+}
+
+// newTestRom returns an NROM-128 ROM running code from PROGRAM_START, the code ends in an endless loop.
+func newTestRom(t *testing.T, code []byte) *reader.NesRom {
+	code = concat(code, jumpToSelf(PROGRAM_START+uint(len(code))))
+	image := make([]byte, reader.NES_HEADER_SIZE+reader.PROGRAM_ROM_SIZE+reader.CHARACTER_ROM_SIZE)
+	copy(image, []byte{'N', 'E', 'S', 0x1A, 1, 1})
+	copy(image[reader.NES_HEADER_SIZE:], code)
+	// Reset vector: 0xC000
+	image[reader.NES_HEADER_SIZE+reader.PROGRAM_ROM_SIZE-3] = 0xC0
+	rom, err := reader.Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rom
+}
+
+func TestRun(t *testing.T) {
+	running := report(STATUS_RUNNING, "")
+	// LDA $6010, BNE: the first run sets 0x6010 and asks for a reset, the second one passes.
+	first := concat(store(0x6010, 0x01), report(STATUS_RESET, ""))
+	first = concat(first, jumpToSelf(PROGRAM_START+5+uint(len(first))))
+	reset := concat([]byte{0xAD, 0x10, 0x60, 0xD0, byte(len(first))}, first, report(0x00, "Passed after reset\n"))
+
+	tests := []struct {
+		name      string
+		code      []byte
+		timeout   time.Duration
+		passed    bool
+		isTimeout bool
+		status    byte
+		message   string
+		summary   string
+		// The reset is pressed RESET_DELAY after the request.
+		minElapsed time.Duration
+	}{
+		{"passed", concat(running, report(0x00, "Passed\n")), time.Second, true, false, 0x00, "Passed\n", "Passed", 0},
+		{"failed", concat(running, report(0x03, "Failed\nline 2\n")), time.Second, false, false, 0x03, "Failed\nline 2\n", "#3 Failed", 0},
+		{"reset", reset, 2 * time.Second, true, false, 0x00, "Passed after reset\n", "Passed after reset", RESET_DELAY},
+		{"running", running, 200 * time.Millisecond, false, true, STATUS_RUNNING, "", "timeout", 0},
+		{"no signature", store(STATUS_ADDR, 0x00), 200 * time.Millisecond, false, true, 0x00, "", "timeout", 0},
+	}
+	for _, test := range tests {
+		result, err := Run(newTestRom(t, test.code), test.timeout)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if result.Passed() != test.passed || result.IsTimeout != test.isTimeout || result.Status != test.status {
+			t.Errorf("%s: passed %t, timeout %t, status 0x%02X", test.name, result.Passed(), result.IsTimeout, result.Status)
+		}
+		if result.Message != test.message || result.Summary() != test.summary {
+			t.Errorf("%s: message %q, summary %q", test.name, result.Message, result.Summary())
+		}
+		if result.Elapsed < test.minElapsed {
+			t.Errorf("%s: result after %s", test.name, result.Elapsed)
+		}
+	}
+}
+
+func TestRunJammed(t *testing.T) {
+	result, err := Run(newTestRom(t, []byte{0x02}), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.IsJammed || result.IsTimeout || result.Passed() || result.Summary() != result.JamMessage {
+		t.Errorf("jammed %t, timeout %t, summary %q", result.IsJammed, result.IsTimeout, result.Summary())
+	}
+}
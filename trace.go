@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/popsul/gones/disasm"
+	"github.com/popsul/gones/trace"
+	"io"
+	"os"
+)
+
+// tracing owns the trace output of the -trace flags, a nil *tracing is disabled.
+type tracing struct {
+	logger *trace.Logger
+	writer *bufio.Writer
+	file   *os.File
+}
+
+// startTracing writes every traced line to path, or with ring > 0 keeps the last ring lines
+// for Crash (written to path, stderr without one).
+func startTracing(nes *Nes, path string, addrRange string, ring int) (*tracing, error) {
+	if path == "" && ring <= 0 {
+		return nil, nil
+	}
+	T := new(tracing)
+	var out io.Writer = os.Stderr
+	if path == "-" {
+		out = os.Stdout
+	} else if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		T.file = file
+		out = file
+	}
+	T.writer = bufio.NewWriter(out)
+	// NOTE: Peek does not touch I/O registers, so tracing does not change the emulation.
+	T.logger = trace.NewLogger(T.writer, disasm.ReaderFunc(nes.console.Peek), nes.console.Ppu().Position)
+	if addrRange != "" {
+		from, to, err := trace.ParseRange(addrRange)
+		if err != nil {
+			T.Close()
+			return nil, err
+		}
+		T.logger.SetRange(from, to)
+	}
+	T.logger.SetRing(ring)
+	nes.console.Cpu().SetTracer(T.logger)
+	return T, nil
+}
+
+// Crash writes the ring buffer, the last instructions before the crash.
+func (T *tracing) Crash() {
+	if T == nil {
+		return
+	}
+	if err := T.logger.DumpRing(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func (T *tracing) Close() {
+	if T == nil {
+		return
+	}
+	if err := T.writer.Flush(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if T.file != nil {
+		_ = T.file.Close()
+	}
+}
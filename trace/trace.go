@@ -0,0 +1,195 @@
+package trace
+
+import (
+	"errors"
+	"fmt"
+	"github.com/popsul/gones/cpu"
+	"github.com/popsul/gones/disasm"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+	nestest.log format, one line per instruction before it runs
+
+	C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7
+	C72A  A1 80     LDA ($80,X) @ 80 = 0200 = 5A    A:00 X:00 Y:00 P:26 SP:FB PPU: 14,270 CYC:1542
+	C6BD  04 A9    *NOP $A9 = 00                    A:AA X:97 Y:4E P:EF SP:F5 PPU:  2, 94 CYC:...
+
+	| column | content                                                           |
+	+--------+-------------------------------------------------------------------+
+	|  0     | PC                                                                |
+	|  6     | raw bytes                                                         |
+	| 15     | '*' for unofficial opcodes                                        |
+	| 16     | disassembly, memory operands annotated with the effective address |
+	|        | (@) and the value (=) read before the instruction runs            |
+	| 48     | registers, P without B and with bit 5 set                         |
+	|        | PPU scanline and dot, CPU cycles since power on                   |
+*/
+
+type Logger struct {
+	writer   io.Writer
+	memory   disasm.Reader
+	position func() (uint, uint)
+	from, to uint
+	// Ring buffer of the last lines, nil when the lines are written right away.
+	ring   []string
+	next   int
+	isFull bool
+}
+
+// NewLogger traces to writer, memory must be readable without side effects and
+// position returns the PPU scanline and dot.
+func NewLogger(writer io.Writer, memory disasm.Reader, position func() (uint, uint)) *Logger {
+	logger := new(Logger)
+	logger.writer = writer
+	logger.memory = memory
+	logger.position = position
+	logger.from = 0x0000
+	logger.to = 0xFFFF
+	return logger
+}
+
+// SetRange only traces instructions with PC in from-to (inclusive).
+func (L *Logger) SetRange(from uint, to uint) {
+	L.from = from
+	L.to = to
+}
+
+// SetRing keeps the last size lines in memory instead of writing them, see. DumpRing
+func (L *Logger) SetRing(size int) {
+	L.ring = nil
+	if size > 0 {
+		L.ring = make([]string, size)
+	}
+	L.next = 0
+	L.isFull = false
+}
+
+func (L *Logger) Trace(C *cpu.Cpu) {
+	registers := C.Registers()
+	if registers.PC < L.from || registers.PC > L.to {
+		return
+	}
+	line, dot := L.position()
+	text := L.Format(registers, line, dot, C.Cycles())
+	if L.ring == nil {
+		_, _ = io.WriteString(L.writer, text+"\n")
+		return
+	}
+	L.ring[L.next] = text
+	L.next++
+	if L.next == len(L.ring) {
+		L.next = 0
+		L.isFull = true
+	}
+}
+
+// DumpRing writes the buffered lines, oldest first, and empties the buffer.
+func (L *Logger) DumpRing() error {
+	if L.ring == nil {
+		return nil
+	}
+	lines := L.ring[:L.next]
+	if L.isFull {
+		lines = append(append([]string{}, L.ring[L.next:]...), lines...)
+	}
+	for _, text := range lines {
+		if _, err := io.WriteString(L.writer, text+"\n"); err != nil {
+			return err
+		}
+	}
+	L.SetRing(len(L.ring))
+	return nil
+}
+
+// Format returns the trace line of the instruction at registers.PC.
+func (L *Logger) Format(registers cpu.Registers, line uint, dot uint, cycles uint64) string {
+	inst := disasm.Decode(L.memory, registers.PC)
+	mark := " "
+	if inst.IsUnofficial {
+		mark = "*"
+	}
+	return fmt.Sprintf(
+		"%04X  %-8s %s%-32sA:%02X X:%02X Y:%02X P:%02X SP:%02X PPU:%3d,%3d CYC:%d",
+		registers.PC,
+		inst.HexBytes(),
+		mark,
+		inst.String()+L.annotate(inst, registers),
+		registers.A,
+		registers.X,
+		registers.Y,
+		registers.P.Byte()&^0x10|0x20,
+		registers.SP&0xFF,
+		line,
+		dot,
+		cycles,
+	)
+}
+
+func (L *Logger) annotate(inst disasm.Instruction, registers cpu.Registers) string {
+	operand := inst.Operand
+	switch inst.Addressing {
+	case cpu.ZeroPage:
+		return fmt.Sprintf(" = %02X", L.memory.Read(operand))
+	case cpu.ZeroPageX:
+		addr := (operand + registers.X) & 0xFF
+		return fmt.Sprintf(" @ %02X = %02X", addr, L.memory.Read(addr))
+	case cpu.ZeroPageY:
+		addr := (operand + registers.Y) & 0xFF
+		return fmt.Sprintf(" @ %02X = %02X", addr, L.memory.Read(addr))
+	case cpu.Absolute:
+		if inst.Name == "JMP" || inst.Name == "JSR" {
+			return ""
+		}
+		return fmt.Sprintf(" = %02X", L.memory.Read(operand))
+	case cpu.AbsoluteX:
+		addr := (operand + registers.X) & 0xFFFF
+		return fmt.Sprintf(" @ %04X = %02X", addr, L.memory.Read(addr))
+	case cpu.AbsoluteY:
+		addr := (operand + registers.Y) & 0xFFFF
+		return fmt.Sprintf(" @ %04X = %02X", addr, L.memory.Read(addr))
+	case cpu.PreIndexedIndirect:
+		pointer := (operand + registers.X) & 0xFF
+		addr := L.readZeroPageWord(pointer)
+		return fmt.Sprintf(" @ %02X = %04X = %02X", pointer, addr, L.memory.Read(addr))
+	case cpu.PostIndexedIndirect:
+		base := L.readZeroPageWord(operand)
+		addr := (base + registers.Y) & 0xFFFF
+		return fmt.Sprintf(" = %04X @ %04X = %02X", base, addr, L.memory.Read(addr))
+	case cpu.IndirectAbsolute:
+		// INFO: The pointer's high byte is read from the same page.
+		high := (operand & 0xFF00) | ((operand + 1) & 0xFF)
+		addr := uint(L.memory.Read(operand)) | uint(L.memory.Read(high))<<8
+		return fmt.Sprintf(" = %04X", addr)
+	}
+	return ""
+}
+
+func (L *Logger) readZeroPageWord(addr uint) uint {
+	return uint(L.memory.Read(addr&0xFF)) | uint(L.memory.Read((addr+1)&0xFF))<<8
+}
+
+// ParseRange parses an address range like "0xC000-0xC5FF", a single address traces one instruction address.
+func ParseRange(value string) (uint, uint, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) > 2 {
+		return 0, 0, errors.New("invalid address range: " + value)
+	}
+	from, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 0, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	to := from
+	if len(parts) == 2 {
+		to, err = strconv.ParseUint(strings.TrimSpace(parts[1]), 0, 16)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if to < from {
+		return 0, 0, errors.New("invalid address range: " + value)
+	}
+	return uint(from), uint(to), nil
+}
@@ -0,0 +1,124 @@
+package trace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/popsul/gones/cpu"
+	"github.com/popsul/gones/interrupts"
+)
+
+func status(p byte) *cpu.Status {
+	return cpu.NewStatus(p&0x80 > 0, p&0x40 > 0, p&0x20 > 0, p&0x10 > 0, p&0x08 > 0, p&0x04 > 0, p&0x02 > 0, p&0x01 > 0)
+}
+
+func newTestMemory() *cpu.Memory {
+	memory := cpu.NewMemory()
+	memory.Load(0xC000, []byte{0x4C, 0xF5, 0xC5})
+	memory.Load(0xC100, []byte{
+		0xA5, 0x10, 0xB5, 0x10, 0xBE, 0x00, 0x03, 0xA1, 0x80, 0xB1, 0x89, 0x6C, 0xFF, 0x02, 0x04, 0xA9,
+		0x20, 0x00, 0xC0,
+	})
+	memory.Load(0x0080, []byte{0x00, 0x02})
+	memory.Load(0x0089, []byte{0x00, 0x03})
+	memory.Write(0x0010, 0x33)
+	memory.Write(0x0200, 0x5A)
+	memory.Write(0x02FF, 0x7E)
+	memory.Write(0x0305, 0x44)
+	return memory
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		pc          uint
+		a, x, y, sp uint
+		p           byte
+		line, dot   uint
+		cycles      uint64
+		want        string
+	}{
+		{"absolute JMP", 0xC000, 0x00, 0x00, 0x00, 0x1FD, 0x24, 0, 21, 7,
+			"C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7"},
+		{"zero page", 0xC100, 0x00, 0x00, 0x00, 0x1FD, 0x24, 0, 21, 7,
+			"C100  A5 10     LDA $10 = 33                    A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7"},
+		{"zero page,X", 0xC102, 0x00, 0x05, 0x00, 0x1FD, 0x24, 0, 21, 7,
+			"C102  B5 10     LDA $10,X @ 15 = 00             A:00 X:05 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7"},
+		{"absolute,Y", 0xC104, 0x00, 0x00, 0x05, 0x1FD, 0x24, 0, 21, 7,
+			"C104  BE 00 03  LDX $0300,Y @ 0305 = 44         A:00 X:00 Y:05 P:24 SP:FD PPU:  0, 21 CYC:7"},
+		{"(indirect,X)", 0xC107, 0x00, 0x00, 0x00, 0x1FB, 0x26, 14, 270, 1542,
+			"C107  A1 80     LDA ($80,X) @ 80 = 0200 = 5A    A:00 X:00 Y:00 P:26 SP:FB PPU: 14,270 CYC:1542"},
+		{"(indirect),Y", 0xC109, 0x00, 0x00, 0x05, 0x1FD, 0x24, 0, 21, 7,
+			"C109  B1 89     LDA ($89),Y = 0300 @ 0305 = 44  A:00 X:00 Y:05 P:24 SP:FD PPU:  0, 21 CYC:7"},
+		{"indirect, page wrap", 0xC10B, 0x00, 0x00, 0x00, 0x1FD, 0x24, 0, 21, 7,
+			"C10B  6C FF 02  JMP ($02FF) = 5A7E              A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7"},
+		// B is not shown, the reserved bit always is.
+		{"unofficial", 0xC10E, 0xAA, 0x97, 0x4E, 0x1F5, 0xDF, 241, 340, 29658,
+			"C10E  04 A9    *NOP $A9 = 00                    A:AA X:97 Y:4E P:EF SP:F5 PPU:241,340 CYC:29658"},
+		{"JSR", 0xC110, 0x00, 0x00, 0x00, 0x1FD, 0x24, 0, 21, 7,
+			"C110  20 00 C0  JSR $C000                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7"},
+	}
+	logger := NewLogger(nil, newTestMemory(), nil)
+	for _, test := range tests {
+		registers := cpu.Registers{A: test.a, X: test.x, Y: test.y, SP: test.sp, PC: test.pc, P: status(test.p)}
+		if text := logger.Format(registers, test.line, test.dot, test.cycles); text != test.want {
+			t.Errorf("%s:\n got %q\nwant %q", test.name, text, test.want)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	memory := newTestMemory()
+	// Reset vector: 0xC000
+	memory.Load(0xFFFC, []byte{0x00, 0xC0})
+	c := cpu.NewCpu(memory, interrupts.NewInterrupts())
+	c.PowerOn()
+	var buffer bytes.Buffer
+	logger := NewLogger(&buffer, memory, func() (uint, uint) { return 0, 21 })
+	c.SetTracer(logger)
+	c.Run()
+	want := "C000  4C F5 C5  JMP $C5F5                       A:00 X:00 Y:00 P:24 SP:FD PPU:  0, 21 CYC:7\n"
+	if buffer.String() != want {
+		t.Errorf("got %q\nwant %q", buffer.String(), want)
+	}
+
+	buffer.Reset()
+	logger.SetRing(2)
+	logger.SetRange(0xC000, 0xC0FF)
+	c.SetPC(0xC000)
+	for i := 0; i < 3; i++ {
+		c.Run()
+	}
+	if buffer.Len() != 0 {
+		t.Fatalf("ring wrote %q", buffer.String())
+	}
+	if err := logger.DumpRing(); err != nil {
+		t.Fatal(err)
+	}
+	// Only the JMP at C000 is in range, the BRK at C5F5 and its handler are not.
+	if lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n"); len(lines) != 1 || !strings.HasPrefix(lines[0], "C000") {
+		t.Errorf("ring %q", lines)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		value    string
+		from, to uint
+		isError  bool
+	}{
+		{"0xC000-0xC5FF", 0xC000, 0xC5FF, false},
+		{"0xC000 - 0xC5FF", 0xC000, 0xC5FF, false},
+		{"0x8000", 0x8000, 0x8000, false},
+		{"0xC5FF-0xC000", 0, 0, true},
+		{"0x10000", 0, 0, true},
+		{"1-2-3", 0, 0, true},
+	}
+	for _, test := range tests {
+		from, to, err := ParseRange(test.value)
+		if (err != nil) != test.isError || from != test.from || to != test.to {
+			t.Errorf("%s: %04X-%04X, %v", test.value, from, to, err)
+		}
+	}
+}